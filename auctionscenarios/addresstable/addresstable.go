@@ -0,0 +1,57 @@
+package addresstable
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+)
+
+type Auctioneer struct {
+	Guid string
+	Host string
+}
+
+// A Table lists where a cluster's reps and auctioneers listen, for clusters
+// that aren't routed through a Diego deployment.
+type Table struct {
+	Reps        []auctiontypes.RepAddress
+	Auctioneers []Auctioneer
+}
+
+func Load(path string) (Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Table{}, err
+	}
+	defer f.Close()
+
+	var table Table
+	err = json.NewDecoder(f).Decode(&table)
+	return table, err
+}
+
+func (t Table) Write(path string) error {
+	payload, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, payload, 0644)
+}
+
+func (t Table) AuctioneerHosts() []string {
+	hosts := []string{}
+	for _, auctioneer := range t.Auctioneers {
+		hosts = append(hosts, auctioneer.Host)
+	}
+	return hosts
+}
+
+func (t Table) AuctioneerGuids() []string {
+	guids := []string{}
+	for _, auctioneer := range t.Auctioneers {
+		guids = append(guids, auctioneer.Guid)
+	}
+	return guids
+}
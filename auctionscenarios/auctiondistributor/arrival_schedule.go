@@ -0,0 +1,258 @@
+package auctiondistributor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/auction/util"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+const (
+	BurstArrivals    = "burst"
+	ConstantArrivals = "constant"
+	PoissonArrivals  = "poisson"
+	TraceArrivals    = "trace"
+)
+
+var ArrivalSchedules = []string{BurstArrivals, ConstantArrivals, PoissonArrivals, TraceArrivals}
+
+// An ArrivalSchedule decides when each auction arrives, as an offset from the
+// moment its auctioneer starts work.  Offsets are returned in order and never
+// decrease.
+type ArrivalSchedule interface {
+	Name() string
+	Arrivals(numAuctions int) []time.Duration
+}
+
+// NewArrivalSchedule looks a schedule up by name.  The rate (auctions per
+// second) is used by the constant and poisson schedules, the trace path by
+// the trace schedule.
+func NewArrivalSchedule(name string, rate float64, tracePath string) (ArrivalSchedule, error) {
+	switch name {
+	case BurstArrivals:
+		return burstArrivalSchedule{}, nil
+	case ConstantArrivals, PoissonArrivals:
+		if rate <= 0 {
+			return nil, fmt.Errorf("the %s arrival schedule needs a positive rate, got %f", name, rate)
+		}
+		if name == ConstantArrivals {
+			return constantArrivalSchedule{rate: rate}, nil
+		}
+		return poissonArrivalSchedule{rate: rate}, nil
+	case TraceArrivals:
+		trace, err := loadArrivalTrace(tracePath)
+		if err != nil {
+			return nil, err
+		}
+		return traceArrivalSchedule{trace: trace}, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival schedule: %s", name)
+	}
+}
+
+type burstArrivalSchedule struct{}
+
+func (burstArrivalSchedule) Name() string {
+	return BurstArrivals
+}
+
+func (burstArrivalSchedule) Arrivals(numAuctions int) []time.Duration {
+	return make([]time.Duration, numAuctions)
+}
+
+type constantArrivalSchedule struct {
+	rate float64
+}
+
+func (s constantArrivalSchedule) Name() string {
+	return fmt.Sprintf("%s-%g", ConstantArrivals, s.rate)
+}
+
+func (s constantArrivalSchedule) Arrivals(numAuctions int) []time.Duration {
+	arrivals := make([]time.Duration, numAuctions)
+	for i := range arrivals {
+		arrivals[i] = time.Duration(float64(i) / s.rate * float64(time.Second))
+	}
+	return arrivals
+}
+
+type poissonArrivalSchedule struct {
+	rate float64
+}
+
+func (s poissonArrivalSchedule) Name() string {
+	return fmt.Sprintf("%s-%g", PoissonArrivals, s.rate)
+}
+
+func (s poissonArrivalSchedule) Arrivals(numAuctions int) []time.Duration {
+	arrivals := make([]time.Duration, numAuctions)
+	t := 0.0
+	for i := range arrivals {
+		arrivals[i] = time.Duration(t * float64(time.Second))
+		t += util.R.ExpFloat64() / s.rate
+	}
+	return arrivals
+}
+
+// traceArrivalSchedule replays recorded arrivals.  A trace that is shorter
+// than the workload is repeated back to back.
+type traceArrivalSchedule struct {
+	trace []time.Duration
+}
+
+func (traceArrivalSchedule) Name() string {
+	return TraceArrivals
+}
+
+func (s traceArrivalSchedule) Arrivals(numAuctions int) []time.Duration {
+	arrivals := make([]time.Duration, numAuctions)
+	span := s.trace[len(s.trace)-1]
+	if len(s.trace) > 1 {
+		span += span / time.Duration(len(s.trace)-1)
+	}
+	for i := range arrivals {
+		arrivals[i] = s.trace[i%len(s.trace)] + time.Duration(i/len(s.trace))*span
+	}
+	return arrivals
+}
+
+// loadArrivalTrace reads one timestamp per line, in (fractional) seconds.
+// Blank lines and lines starting with # are skipped.  The timestamps are
+// sorted and made relative to the earliest one.
+func loadArrivalTrace(path string) ([]time.Duration, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the %s arrival schedule needs a trace file", TraceArrivals)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	timestamps := []float64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		timestamp, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in %s: %s", path, line)
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(timestamps) == 0 {
+		return nil, fmt.Errorf("no timestamps in %s", path)
+	}
+
+	sort.Float64s(timestamps)
+	trace := make([]time.Duration, len(timestamps))
+	for i, timestamp := range timestamps {
+		trace[i] = time.Duration((timestamp - timestamps[0]) * float64(time.Second))
+	}
+	return trace, nil
+}
+
+func scheduleStartAuctionRequests(requests []auctiontypes.StartAuctionRequest, arrivals map[string]time.Duration) []ScheduledStartAuctionRequest {
+	scheduledRequests := []ScheduledStartAuctionRequest{}
+	for _, request := range requests {
+		scheduledRequests = append(scheduledRequests, ScheduledStartAuctionRequest{
+			StartAuctionRequest: request,
+			ArrivalOffset:       arrivals[startAuctionKey(request.LRPStartAuction)],
+		})
+	}
+	return scheduledRequests
+}
+
+func startAuctionArrivals(startAuctions []models.LRPStartAuction, schedule ArrivalSchedule) map[string]time.Duration {
+	arrivals := map[string]time.Duration{}
+	for i, arrival := range schedule.Arrivals(len(startAuctions)) {
+		arrivals[startAuctionKey(startAuctions[i])] = arrival
+	}
+	return arrivals
+}
+
+func scheduleStopAuctionRequests(requests []auctiontypes.StopAuctionRequest, arrivals map[string]time.Duration) []ScheduledStopAuctionRequest {
+	scheduledRequests := []ScheduledStopAuctionRequest{}
+	for _, request := range requests {
+		scheduledRequests = append(scheduledRequests, ScheduledStopAuctionRequest{
+			StopAuctionRequest: request,
+			ArrivalOffset:      arrivals[stopAuctionKey(request.LRPStopAuction)],
+		})
+	}
+	return scheduledRequests
+}
+
+// ReleaseOnArrival hands each request to release once its arrival offset,
+// measured from start, has passed, and stops releasing once ctx is done.
+// A release that blocks delays the requests behind it, but they keep their
+// scheduled arrival, so any wait measured from it includes the backlog.
+func ReleaseOnArrival(ctx context.Context, start time.Time, requests []ScheduledStartAuctionRequest, release func(ScheduledStartAuctionRequest)) {
+	offsets := make([]time.Duration, len(requests))
+	for i, request := range requests {
+		offsets[i] = request.ArrivalOffset
+	}
+	releaseOnArrival(ctx, start, offsets, func(i int) {
+		release(requests[i])
+	})
+}
+
+// ReleaseStopAuctionsOnArrival is ReleaseOnArrival for stop auctions.
+func ReleaseStopAuctionsOnArrival(ctx context.Context, start time.Time, requests []ScheduledStopAuctionRequest, release func(ScheduledStopAuctionRequest)) {
+	offsets := make([]time.Duration, len(requests))
+	for i, request := range requests {
+		offsets[i] = request.ArrivalOffset
+	}
+	releaseOnArrival(ctx, start, offsets, func(i int) {
+		release(requests[i])
+	})
+}
+
+// releaseOnArrival releases indexes into offsets in order of arrival, keeping
+// the given order among equal offsets.
+func releaseOnArrival(ctx context.Context, start time.Time, offsets []time.Duration, release func(int)) {
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Stable(byArrivalOffset{order: order, offsets: offsets})
+
+	for _, i := range order {
+		wait := start.Add(offsets[i]).Sub(time.Now())
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		release(i)
+	}
+}
+
+type byArrivalOffset struct {
+	order   []int
+	offsets []time.Duration
+}
+
+func (b byArrivalOffset) Len() int      { return len(b.order) }
+func (b byArrivalOffset) Swap(i, j int) { b.order[i], b.order[j] = b.order[j], b.order[i] }
+func (b byArrivalOffset) Less(i, j int) bool {
+	return b.offsets[b.order[i]] < b.offsets[b.order[j]]
+}
@@ -0,0 +1,143 @@
+package auctiondistributor_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+var _ = Describe("ArrivalSchedule", func() {
+	var dir string
+
+	writeTrace := func(contents string) string {
+		path := filepath.Join(dir, "trace.txt")
+		err := ioutil.WriteFile(path, []byte(contents), 0666)
+		Ω(err).ShouldNot(HaveOccurred())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "arrivals")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("schedules a burst all at once", func() {
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.BurstArrivals, 0, "")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(schedule.Name()).Should(Equal("burst"))
+		Ω(schedule.Arrivals(3)).Should(Equal([]time.Duration{0, 0, 0}))
+	})
+
+	It("spaces constant arrivals evenly", func() {
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.ConstantArrivals, 2, "")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(schedule.Name()).Should(Equal("constant-2"))
+		Ω(schedule.Arrivals(4)).Should(Equal([]time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}))
+	})
+
+	It("schedules poisson arrivals from the start, never decreasing", func() {
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.PoissonArrivals, 100, "")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(schedule.Name()).Should(Equal("poisson-100"))
+
+		arrivals := schedule.Arrivals(1000)
+		Ω(arrivals).Should(HaveLen(1000))
+		Ω(arrivals[0]).Should(Equal(time.Duration(0)))
+		for i := 1; i < len(arrivals); i++ {
+			Ω(arrivals[i]).Should(BeNumerically(">=", arrivals[i-1]))
+		}
+		Ω(arrivals[999].Seconds()).Should(BeNumerically("~", 10, 2))
+	})
+
+	It("replays a trace from its earliest timestamp, skipping comments and blank lines", func() {
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, writeTrace("# seconds\n11\n\n10\n10.5\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(schedule.Name()).Should(Equal("trace"))
+		Ω(schedule.Arrivals(3)).Should(Equal([]time.Duration{0, 500 * time.Millisecond, time.Second}))
+	})
+
+	It("repeats a trace back to back when there are more auctions than timestamps", func() {
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, writeTrace("10\n10.5\n11\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(schedule.Arrivals(5)).Should(Equal([]time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond, 2 * time.Second}))
+	})
+
+	It("rejects unknown schedules and rates that aren't positive", func() {
+		_, err := auctiondistributor.NewArrivalSchedule("sometimes", 1, "")
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewArrivalSchedule(auctiondistributor.ConstantArrivals, 0, "")
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewArrivalSchedule(auctiondistributor.PoissonArrivals, -1, "")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("rejects missing, empty and malformed traces", func() {
+		_, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, "")
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, filepath.Join(dir, "missing.txt"))
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, writeTrace("# nothing\n"))
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewArrivalSchedule(auctiondistributor.TraceArrivals, 0, writeTrace("10\nlater\n"))
+		Ω(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("ReleaseOnArrival", func() {
+	requests := func(offsets ...time.Duration) []auctiondistributor.ScheduledStartAuctionRequest {
+		scheduledRequests := []auctiondistributor.ScheduledStartAuctionRequest{}
+		for i, offset := range offsets {
+			request := auctiondistributor.ScheduledStartAuctionRequest{ArrivalOffset: offset}
+			request.LRPStartAuction.Index = i
+			scheduledRequests = append(scheduledRequests, request)
+		}
+		return scheduledRequests
+	}
+
+	It("releases the requests in order of arrival, keeping the order of equal offsets", func() {
+		released := []int{}
+		start := time.Now()
+		auctiondistributor.ReleaseOnArrival(context.Background(), start, requests(20*time.Millisecond, 0, 10*time.Millisecond, 0), func(request auctiondistributor.ScheduledStartAuctionRequest) {
+			released = append(released, request.LRPStartAuction.Index)
+		})
+		Ω(released).Should(Equal([]int{1, 3, 2, 0}))
+		Ω(time.Since(start)).Should(BeNumerically(">=", 20*time.Millisecond))
+	})
+
+	It("stops releasing once the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		released := []int{}
+		auctiondistributor.ReleaseOnArrival(ctx, time.Now(), requests(0, time.Hour, 0), func(request auctiondistributor.ScheduledStartAuctionRequest) {
+			released = append(released, request.LRPStartAuction.Index)
+		})
+		Ω(released).Should(Equal([]int{0, 2}))
+	})
+
+	It("releases stop auctions the same way", func() {
+		stopRequests := []auctiondistributor.ScheduledStopAuctionRequest{}
+		for i, offset := range []time.Duration{10 * time.Millisecond, 0} {
+			request := auctiondistributor.ScheduledStopAuctionRequest{ArrivalOffset: offset}
+			request.LRPStopAuction.Index = i
+			stopRequests = append(stopRequests, request)
+		}
+
+		released := []int{}
+		auctiondistributor.ReleaseStopAuctionsOnArrival(context.Background(), time.Now(), stopRequests, func(request auctiondistributor.ScheduledStopAuctionRequest) {
+			released = append(released, request.LRPStopAuction.Index)
+		})
+		Ω(released).Should(Equal([]int{1, 0}))
+	})
+})
@@ -1,13 +1,29 @@
 package auctiondistributor
 
 import (
+	"context"
+	"time"
+
 	"github.com/cloudfoundry-incubator/auction/auctiontypes"
 	"github.com/cloudfoundry-incubator/runtime-schema/models"
 )
 
+// DefaultAuctionTimeout bounds HoldStartAuctions and HoldStopAuctions; use the
+// WithContext variants to pick a different deadline or to cancel a run.
+var DefaultAuctionTimeout = 5 * time.Minute
+
+// The WithContext variants return whatever results arrived before the context
+// was done, along with a RunOutcome that says whether the run was complete and
+// accounts for any auctioneer that failed along the way.
 type AuctionDistributor interface {
 	HoldStartAuctions(numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) []auctiontypes.StartAuctionResult
 	HoldStopAuctions(numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) []auctiontypes.StopAuctionResult
+
+	HoldStartAuctionsWithContext(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome)
+	HoldStopAuctionsWithContext(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome)
+
+	HoldWorkload(numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) WorkloadResults
+	HoldWorkloadWithContext(ctx context.Context, numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) (WorkloadResults, RunOutcome)
 }
 
 func buildStartAuctionRequests(startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) []auctiontypes.StartAuctionRequest {
@@ -32,3 +48,29 @@ func buildStopAuctionRequests(stopAuctions []models.LRPStopAuction, repAddresses
 	}
 	return requests
 }
+
+func groupStartAuctionRequests(requests []auctiontypes.StartAuctionRequest, numAuctioneers int, partitionStrategy PartitionStrategy) map[int][]auctiontypes.StartAuctionRequest {
+	processGuids := make([]string, len(requests))
+	for i, request := range requests {
+		processGuids[i] = request.LRPStartAuction.DesiredLRP.ProcessGuid
+	}
+
+	groupedRequests := map[int][]auctiontypes.StartAuctionRequest{}
+	for i, index := range partitionStrategy.Partition(processGuids, numAuctioneers) {
+		groupedRequests[index] = append(groupedRequests[index], requests[i])
+	}
+	return groupedRequests
+}
+
+func groupStopAuctionRequests(requests []auctiontypes.StopAuctionRequest, numAuctioneers int, partitionStrategy PartitionStrategy) map[int][]auctiontypes.StopAuctionRequest {
+	processGuids := make([]string, len(requests))
+	for i, request := range requests {
+		processGuids[i] = request.LRPStopAuction.ProcessGuid
+	}
+
+	groupedRequests := map[int][]auctiontypes.StopAuctionRequest{}
+	for i, index := range partitionStrategy.Partition(processGuids, numAuctioneers) {
+		groupedRequests[index] = append(groupedRequests[index], requests[i])
+	}
+	return groupedRequests
+}
@@ -0,0 +1,13 @@
+package auctiondistributor_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAuctionDistributor(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Auction Distributor Suite")
+}
@@ -0,0 +1,3 @@
+package auctiondistributor
+
+var WorkloadArrivals = workloadArrivals
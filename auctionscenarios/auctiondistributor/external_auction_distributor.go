@@ -2,7 +2,9 @@ package auctiondistributor
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -19,113 +21,427 @@ type externalAuctionDistributor struct {
 	hosts                    []string
 	auctionCommunicationMode string
 	maxConcurrent            int
+	partitionStrategy        PartitionStrategy
+	arrivalSchedule          ArrivalSchedule
+	retryPolicy              RetryPolicy
 }
 
-func NewExternalAuctionDistributor(hosts []string, maxConcurrent int, auctionCommunicationMode string) AuctionDistributor {
+func NewExternalAuctionDistributor(hosts []string, maxConcurrent int, auctionCommunicationMode string, retryPolicy RetryPolicy, partitionStrategy PartitionStrategy, arrivalSchedule ArrivalSchedule) AuctionDistributor {
 	return &externalAuctionDistributor{
 		auctionCommunicationMode: auctionCommunicationMode,
 		maxConcurrent:            maxConcurrent,
 		hosts:                    hosts,
+		retryPolicy:              retryPolicy,
+		partitionStrategy:        partitionStrategy,
+		arrivalSchedule:          arrivalSchedule,
 	}
 }
 
 func (d *externalAuctionDistributor) HoldStartAuctions(numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) []auctiontypes.StartAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStartAuctionsWithContext(ctx, numAuctioneers, startAuctions, repAddresses, rules)
+	return results
+}
+
+func (d *externalAuctionDistributor) HoldStartAuctionsWithContext(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
+	return d.holdStartAuctions(ctx, numAuctioneers, startAuctions, startAuctionArrivals(startAuctions, d.arrivalSchedule), repAddresses, rules)
+}
+
+func (d *externalAuctionDistributor) holdStartAuctions(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
 	startAuctionRequests := buildStartAuctionRequests(startAuctions, repAddresses, rules)
-	groupedRequests := map[int][]auctiontypes.StartAuctionRequest{}
-	i := 0
-	for _, request := range startAuctionRequests {
-		index := i % numAuctioneers
-		groupedRequests[index] = append(groupedRequests[index], request)
-		i++
-	}
+	groupedRequests := groupStartAuctionRequests(startAuctionRequests, numAuctioneers, d.partitionStrategy)
 
 	bar := pb.StartNew(len(startAuctions))
 
-	workPool := workpool.NewWorkPool(50)
+	recorder := newOutcomeRecorder()
+	jobs := d.submitStartAuctions(ctx, recorder, numAuctioneers, groupedRequests, arrivals)
 
+	lock := &sync.Mutex{}
+	seen := map[string]bool{}
+	results := []auctiontypes.StartAuctionResult{}
 	wg := &sync.WaitGroup{}
-	wg.Add(len(groupedRequests))
-	for i := range groupedRequests {
-		i := i
-		workPool.Submit(func() {
+	wg.Add(len(jobs))
+	for _, job := range jobs {
+		go func(host string, job StartAuctionsJob) {
 			defer wg.Done()
-			payload, _ := json.Marshal(groupedRequests[i])
-			url := fmt.Sprintf("http://%s/start-auctions?mode=%s&maxConcurrent=%d", d.hosts[i], d.auctionCommunicationMode, d.maxConcurrent)
-			_, err := http.Post(url, "application/json", bytes.NewReader(payload))
-			if err != nil {
-				fmt.Println("Failed to run auctions on index", i, err.Error())
-				return
+			d.collectStartAuctionResults(ctx, recorder, host, job, func(result []auctiontypes.StartAuctionResult) {
+				lock.Lock()
+				for _, r := range result {
+					key := startAuctionKey(r.LRPStartAuction)
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					results = append(results, r)
+				}
+				bar.Set(len(results))
+				lock.Unlock()
+			})
+			if ctx.Err() != nil {
+				d.cancelJob(host, job.JobID)
 			}
-		})
+			d.deleteJob(host, job.JobID)
+		}(job.host, job.StartAuctionsJob)
 	}
 
 	wg.Wait()
-	workPool.Stop()
+	bar.Finish()
+	return results, recorder.StartOutcome(startAuctions, results, ctx.Err() != nil)
+}
 
-	results := []auctiontypes.StartAuctionResult{}
-	finishedAuctioneers := map[int]bool{}
-	start := time.Now()
-	for {
-		client := &http.Client{
-			Timeout: 3 * time.Second,
+type hostedJob struct {
+	StartAuctionsJob
+	host string
+}
+
+// errBatchGone is returned for a batch the auctioneer already ran and whose
+// results it has since forgotten; running it elsewhere would run it twice.
+var errBatchGone = errors.New("the auctioneer already ran the batch and forgot its results")
+
+// submitStartAuctions hands each batch to its auctioneer, retrying with
+// backoff.  Retries to the same auctioneer reuse the batch id, so an
+// auctioneer runs a batch at most once.  A batch that is never acknowledged
+// is looked up in the auctioneer's jobs, in case it was accepted after all,
+// and is otherwise split across the auctioneers that are still healthy.  If
+// the auctioneer can't be asked either, the batch is redistributed anyway and
+// its auctions are counted as possibly run twice.  Arrival offsets are
+// relative to when an auctioneer accepts its batch, so a redistributed
+// auction arrives late by however long the first round took.
+func (d *externalAuctionDistributor) submitStartAuctions(ctx context.Context, recorder *outcomeRecorder, numAuctioneers int, groupedRequests map[int][]auctiontypes.StartAuctionRequest, arrivals map[string]time.Duration) []hostedJob {
+	jobs := []hostedJob{}
+	unhealthy := map[int]bool{}
+	lock := &sync.Mutex{}
+
+	assignments := groupedRequests
+	for round := 0; len(assignments) > 0; round++ {
+		orphans := map[int][]auctiontypes.StartAuctionRequest{}
+		unconfirmed := map[int]bool{}
+
+		workPool := workpool.NewWorkPool(50)
+		wg := &sync.WaitGroup{}
+		wg.Add(len(assignments))
+		for i, requests := range assignments {
+			i := i
+			requests := requests
+			host := d.hosts[i]
+			recorder.AssignStartAuctions(host, requests)
+			workPool.Submit(func() {
+				defer wg.Done()
+				batchID := fmt.Sprintf("%d-%d-%d", time.Now().UnixNano(), round, i)
+				job, err := d.submitStartAuctionBatch(ctx, recorder, host, batchID, scheduleStartAuctionRequests(requests, arrivals))
+				if err == errBatchGone {
+					return
+				}
+				if err != nil && ctx.Err() == nil {
+					var found bool
+					var lookupErr error
+					job, found, lookupErr = d.findBatch(ctx, host, batchID)
+					if lookupErr != nil {
+						fmt.Println("Failed to look up batch", batchID, "on", host, lookupErr.Error())
+					} else if found {
+						err = nil
+					}
+					lock.Lock()
+					unconfirmed[i] = lookupErr != nil
+					lock.Unlock()
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				if err != nil {
+					unhealthy[i] = true
+					orphans[i] = requests
+					return
+				}
+				jobs = append(jobs, hostedJob{StartAuctionsJob: job, host: host})
+			})
 		}
-		for i := range groupedRequests {
-			if finishedAuctioneers[i] {
-				continue
-			}
-			res, err := client.Get("http://" + d.hosts[i] + "/start-auctions-results")
-			if err != nil {
-				fmt.Println("Failed to get auctions on index", i, err.Error())
-				continue
-			}
-			data, err := ioutil.ReadAll(res.Body)
-			if err != nil {
-				fmt.Println("Failed to read body on index", i, err.Error())
-				res.Body.Close()
-				continue
-			}
-			if res.StatusCode >= 300 {
-				fmt.Println("Got unexpected status code on index", i, res.StatusCode, string(data))
-				res.Body.Close()
-				continue
+		wg.Wait()
+		workPool.Stop()
+
+		if ctx.Err() != nil || len(orphans) == 0 {
+			break
+		}
+
+		healthy := []int{}
+		for i := 0; i < numAuctioneers; i++ {
+			if !unhealthy[i] {
+				healthy = append(healthy, i)
 			}
-			result := []auctiontypes.StartAuctionResult{}
-			err = json.Unmarshal(data, &result)
-			if err != nil {
-				fmt.Println("Failed to decode results on index", i, err.Error(), string(data))
-				res.Body.Close()
-				continue
+		}
+		if len(healthy) == 0 {
+			fmt.Println("No healthy auctioneers left to take over", len(orphans), "batches")
+			break
+		}
+
+		unassigned := []auctiontypes.StartAuctionRequest{}
+		for i, requests := range orphans {
+			recorder.Abandon(d.hosts[i], len(requests))
+			if unconfirmed[i] {
+				recorder.SuspectDuplicates(d.hosts[i], len(requests))
 			}
+			unassigned = append(unassigned, requests...)
+		}
+
+		fmt.Println("Redistributing", len(unassigned), "auctions across", len(healthy), "healthy auctioneers")
+		assignments = map[int][]auctiontypes.StartAuctionRequest{}
+		for i, requests := range groupStartAuctionRequests(unassigned, len(healthy), d.partitionStrategy) {
+			assignments[healthy[i]] = requests
+		}
+	}
+
+	return jobs
+}
+
+// findBatch asks an auctioneer whether it holds a job for the batch.
+func (d *externalAuctionDistributor) findBatch(ctx context.Context, host string, batchID string) (StartAuctionsJob, bool, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, d.retryPolicy.AttemptTimeout)
+	defer cancel()
 
-			if res.StatusCode == http.StatusCreated {
-				finishedAuctioneers[i] = true
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/jobs?batch=%s", host, batchID), nil)
+	if err != nil {
+		return StartAuctionsJob{}, false, err
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(attemptCtx))
+	if err != nil {
+		return StartAuctionsJob{}, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return StartAuctionsJob{}, false, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	jobs := []StartAuctionsJob{}
+	err = json.NewDecoder(res.Body).Decode(&jobs)
+	if err != nil {
+		return StartAuctionsJob{}, false, err
+	}
+	for _, job := range jobs {
+		if job.BatchID == batchID {
+			return job, true, nil
+		}
+	}
+	return StartAuctionsJob{}, false, nil
+}
+
+func (d *externalAuctionDistributor) submitStartAuctionBatch(ctx context.Context, recorder *outcomeRecorder, host string, batchID string, requests []ScheduledStartAuctionRequest) (StartAuctionsJob, error) {
+	payload, _ := json.Marshal(requests)
+	url := fmt.Sprintf("http://%s/start-auctions?mode=%s&maxConcurrent=%d&batch=%s", host, d.auctionCommunicationMode, d.maxConcurrent, batchID)
+
+	err := fmt.Errorf("no attempts left to run auctions on %s", host)
+	for attempt := 1; attempt <= d.retryPolicy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return StartAuctionsJob{}, ctx.Err()
+			case <-time.After(d.retryPolicy.Backoff(attempt - 1)):
 			}
-			results = append(results, result...)
-			bar.Set(len(results))
-			res.Body.Close()
 		}
-		if len(finishedAuctioneers) == len(groupedRequests) {
-			break
+
+		var job StartAuctionsJob
+		job, err = d.postStartAuctionBatch(ctx, recorder, host, url, payload)
+		if err == nil || err == errBatchGone {
+			return job, err
 		}
-		if time.Since(start) > 5*time.Minute {
+		if ctx.Err() != nil {
+			return StartAuctionsJob{}, ctx.Err()
+		}
+		fmt.Println("Failed to run auctions on", host, "attempt", attempt, err.Error())
+	}
+
+	return StartAuctionsJob{}, err
+}
+
+func (d *externalAuctionDistributor) postStartAuctionBatch(ctx context.Context, recorder *outcomeRecorder, host string, url string, payload []byte) (StartAuctionsJob, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, d.retryPolicy.AttemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return StartAuctionsJob{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req.WithContext(attemptCtx))
+	if err != nil {
+		if ctx.Err() == nil {
+			recorder.FailWithError(host, err)
+		}
+		return StartAuctionsJob{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusGone {
+		recorder.Fail(host, StatusFailure, res.StatusCode, errBatchGone.Error())
+		return StartAuctionsJob{}, errBatchGone
+	}
+	if res.StatusCode != http.StatusAccepted {
+		recorder.Fail(host, StatusFailure, res.StatusCode, "unexpected status code when starting auctions")
+		return StartAuctionsJob{}, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	job := StartAuctionsJob{}
+	err = json.NewDecoder(res.Body).Decode(&job)
+	if err != nil {
+		recorder.Fail(host, DecodeFailure, 0, err.Error())
+		return StartAuctionsJob{}, err
+	}
+
+	return job, nil
+}
+
+func (d *externalAuctionDistributor) collectStartAuctionResults(ctx context.Context, recorder *outcomeRecorder, host string, job StartAuctionsJob, onResults func([]auctiontypes.StartAuctionResult)) {
+	offset, finished := d.streamStartAuctionResults(ctx, recorder, host, job, onResults)
+	if finished {
+		return
+	}
+	d.pollStartAuctionResults(ctx, recorder, host, job, offset, onResults)
+}
+
+func (d *externalAuctionDistributor) streamStartAuctionResults(ctx context.Context, recorder *outcomeRecorder, host string, job StartAuctionsJob, onResults func([]auctiontypes.StartAuctionResult)) (int, bool) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/start-auctions-stream?job=%s", host, job.JobID), nil)
+	if err != nil {
+		return 0, false
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		fmt.Println("Failed to stream auctions on", host, "falling back to polling", err.Error())
+		if ctx.Err() == nil {
+			recorder.FailWithError(host, err)
+		}
+		return 0, false
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		fmt.Println("Got unexpected status code when streaming auctions on", host, "falling back to polling", res.StatusCode)
+		if res.StatusCode != http.StatusNotFound {
+			recorder.Fail(host, StatusFailure, res.StatusCode, "unexpected status code when streaming auctions")
+		}
+		return 0, false
+	}
+
+	offset := 0
+	decoder := json.NewDecoder(res.Body)
+	for {
+		result := auctiontypes.StartAuctionResult{}
+		err := decoder.Decode(&result)
+		if err != nil {
 			break
 		}
-		time.Sleep(100 * time.Millisecond)
+		offset++
+		onResults([]auctiontypes.StartAuctionResult{result})
 	}
 
-	bar.Finish()
+	return offset, offset == job.NumAuctions
+}
+
+func (d *externalAuctionDistributor) pollStartAuctionResults(ctx context.Context, recorder *outcomeRecorder, host string, job StartAuctionsJob, offset int, onResults func([]auctiontypes.StartAuctionResult)) {
+	for {
+		finished, n := d.fetchStartAuctionResults(ctx, recorder, host, job, offset, onResults)
+		if finished {
+			return
+		}
+		offset += n
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (d *externalAuctionDistributor) fetchStartAuctionResults(ctx context.Context, recorder *outcomeRecorder, host string, job StartAuctionsJob, offset int, onResults func([]auctiontypes.StartAuctionResult)) (bool, int) {
+	requestCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/start-auctions-results?job=%s&offset=%d", host, job.JobID, offset), nil)
+	if err != nil {
+		return false, 0
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(requestCtx))
+	if err != nil {
+		fmt.Println("Failed to get auctions on", host, err.Error())
+		if ctx.Err() == nil {
+			recorder.FailWithError(host, err)
+		}
+		return false, 0
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		fmt.Println("Failed to read body on", host, err.Error())
+		recorder.FailWithError(host, err)
+		return false, 0
+	}
+	if res.StatusCode >= 300 {
+		fmt.Println("Got unexpected status code on", host, res.StatusCode, string(data))
+		recorder.Fail(host, StatusFailure, res.StatusCode, string(data))
+		return false, 0
+	}
+	result := []auctiontypes.StartAuctionResult{}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		fmt.Println("Failed to decode results on", host, err.Error(), string(data))
+		recorder.Fail(host, DecodeFailure, 0, err.Error())
+		return false, 0
+	}
+
+	onResults(result)
+	return res.StatusCode == http.StatusCreated, len(result)
+}
+
+func (d *externalAuctionDistributor) cancelJob(host string, jobID string) {
+	d.sendJobRequest("POST", fmt.Sprintf("http://%s/jobs/cancel?job=%s", host, jobID))
+}
+
+func (d *externalAuctionDistributor) deleteJob(host string, jobID string) {
+	d.sendJobRequest("DELETE", fmt.Sprintf("http://%s/jobs?job=%s", host, jobID))
+}
+
+func (d *externalAuctionDistributor) sendJobRequest(method string, url string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return
+	}
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		fmt.Println("Failed to", method, url, err.Error())
+		return
+	}
+	res.Body.Close()
+}
+
+func (d *externalAuctionDistributor) HoldWorkload(numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) WorkloadResults {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldWorkloadWithContext(ctx, numAuctioneers, workload, repAddresses, rules)
 	return results
 }
 
+func (d *externalAuctionDistributor) HoldWorkloadWithContext(ctx context.Context, numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) (WorkloadResults, RunOutcome) {
+	return holdWorkload(ctx, d, d.arrivalSchedule, numAuctioneers, workload, repAddresses, rules)
+}
+
 func (d *externalAuctionDistributor) HoldStopAuctions(numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) []auctiontypes.StopAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStopAuctionsWithContext(ctx, numAuctioneers, stopAuctions, repAddresses)
+	return results
+}
+
+func (d *externalAuctionDistributor) HoldStopAuctionsWithContext(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
+	return d.holdStopAuctions(ctx, numAuctioneers, stopAuctions, nil, repAddresses)
+}
+
+func (d *externalAuctionDistributor) holdStopAuctions(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
 	stopAuctionRequests := buildStopAuctionRequests(stopAuctions, repAddresses)
+	groupedRequests := groupStopAuctionRequests(stopAuctionRequests, numAuctioneers, d.partitionStrategy)
 
-	groupedRequests := map[int][]auctiontypes.StopAuctionRequest{}
-	i := 0
-	for _, request := range stopAuctionRequests {
-		index := i % numAuctioneers
-		groupedRequests[index] = append(groupedRequests[index], request)
-		i++
+	recorder := newOutcomeRecorder()
+	for i, requests := range groupedRequests {
+		recorder.AssignStopAuctions(d.hosts[i], requests)
 	}
 
 	results := []auctiontypes.StopAuctionResult{}
@@ -135,17 +451,32 @@ func (d *externalAuctionDistributor) HoldStopAuctions(numAuctioneers int, stopAu
 		if len(groupedRequests[i]) == 0 {
 			continue
 		}
+		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			payload, _ := json.Marshal(groupedRequests[i])
+			payload, _ := json.Marshal(scheduleStopAuctionRequests(groupedRequests[i], arrivals))
 			url := fmt.Sprintf("http://%s/stop-auctions?mode=%s&maxConcurrent=%d", d.hosts[i], d.auctionCommunicationMode, d.maxConcurrent)
 
-			res, err := http.Post(url, "application/json", bytes.NewReader(payload))
+			req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+			if err != nil {
+				fmt.Println("Failed to build request on index", i, err.Error())
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := http.DefaultClient.Do(req.WithContext(ctx))
 			if err != nil {
 				fmt.Println("Failed to run auctions on index", i, err.Error())
+				if ctx.Err() == nil {
+					recorder.FailWithError(d.hosts[i], err)
+				}
 				return
 			}
 			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				fmt.Println("Got unexpected status code when stopping auctions on index", i, res.StatusCode)
+				recorder.Fail(d.hosts[i], StatusFailure, res.StatusCode, "unexpected status code when stopping auctions")
+				return
+			}
 			decoder := json.NewDecoder(res.Body)
 			for {
 				result := auctiontypes.StopAuctionResult{}
@@ -161,5 +492,5 @@ func (d *externalAuctionDistributor) HoldStopAuctions(numAuctioneers int, stopAu
 	}
 
 	wg.Wait()
-	return results
+	return results, recorder.StopOutcome(stopAuctions, results, ctx.Err() != nil)
 }
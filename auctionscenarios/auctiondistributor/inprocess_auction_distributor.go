@@ -0,0 +1,165 @@
+package auctiondistributor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/cloudfoundry-incubator/auction/auctionrunner"
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/gunk/workpool"
+)
+
+type inProcessAuctionDistributor struct {
+	repClient         auctiontypes.RepPoolClient
+	maxConcurrent     int
+	partitionStrategy PartitionStrategy
+	arrivalSchedule   ArrivalSchedule
+}
+
+func NewInProcessAuctionDistributor(repClient auctiontypes.RepPoolClient, maxConcurrent int, partitionStrategy PartitionStrategy, arrivalSchedule ArrivalSchedule) AuctionDistributor {
+	return &inProcessAuctionDistributor{
+		repClient:         repClient,
+		maxConcurrent:     maxConcurrent,
+		partitionStrategy: partitionStrategy,
+		arrivalSchedule:   arrivalSchedule,
+	}
+}
+
+func (d *inProcessAuctionDistributor) HoldStartAuctions(numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) []auctiontypes.StartAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStartAuctionsWithContext(ctx, numAuctioneers, startAuctions, repAddresses, rules)
+	return results
+}
+
+func (d *inProcessAuctionDistributor) HoldStartAuctionsWithContext(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
+	return d.holdStartAuctions(ctx, numAuctioneers, startAuctions, startAuctionArrivals(startAuctions, d.arrivalSchedule), repAddresses, rules)
+}
+
+func (d *inProcessAuctionDistributor) holdStartAuctions(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
+	startAuctionRequests := buildStartAuctionRequests(startAuctions, repAddresses, rules)
+	groupedRequests := groupStartAuctionRequests(startAuctionRequests, numAuctioneers, d.partitionStrategy)
+
+	bar := pb.StartNew(len(startAuctions))
+
+	recorder := newOutcomeRecorder()
+	for i, requests := range groupedRequests {
+		recorder.AssignStartAuctions(inProcessAuctioneerName(i), requests)
+	}
+
+	results := []auctiontypes.StartAuctionResult{}
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(groupedRequests))
+	for i := range groupedRequests {
+		go func(auctioneer string, requests []ScheduledStartAuctionRequest) {
+			defer wg.Done()
+			runner := auctionrunner.New(d.repClient)
+			workers := workpool.NewWorkPool(d.maxConcurrent)
+
+			t := time.Now()
+			auctioneerWG := &sync.WaitGroup{}
+			ReleaseOnArrival(ctx, t, requests, func(request ScheduledStartAuctionRequest) {
+				auctioneerWG.Add(1)
+				workers.Submit(func() {
+					defer auctioneerWG.Done()
+					if ctx.Err() != nil {
+						return
+					}
+					result, err := runner.RunLRPStartAuction(request.StartAuctionRequest)
+					if err != nil {
+						recorder.Fail(auctioneer, AuctionFailure, 0, err.Error())
+					}
+					result.Duration = time.Since(t.Add(request.ArrivalOffset))
+					lock.Lock()
+					results = append(results, result)
+					bar.Set(len(results))
+					lock.Unlock()
+				})
+			})
+
+			auctioneerWG.Wait()
+			workers.Stop()
+		}(inProcessAuctioneerName(i), scheduleStartAuctionRequests(groupedRequests[i], arrivals))
+	}
+
+	wg.Wait()
+	bar.Finish()
+	return results, recorder.StartOutcome(startAuctions, results, ctx.Err() != nil)
+}
+
+func (d *inProcessAuctionDistributor) HoldWorkload(numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) WorkloadResults {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldWorkloadWithContext(ctx, numAuctioneers, workload, repAddresses, rules)
+	return results
+}
+
+func (d *inProcessAuctionDistributor) HoldWorkloadWithContext(ctx context.Context, numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) (WorkloadResults, RunOutcome) {
+	return holdWorkload(ctx, d, d.arrivalSchedule, numAuctioneers, workload, repAddresses, rules)
+}
+
+func (d *inProcessAuctionDistributor) HoldStopAuctions(numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) []auctiontypes.StopAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStopAuctionsWithContext(ctx, numAuctioneers, stopAuctions, repAddresses)
+	return results
+}
+
+func (d *inProcessAuctionDistributor) HoldStopAuctionsWithContext(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
+	return d.holdStopAuctions(ctx, numAuctioneers, stopAuctions, nil, repAddresses)
+}
+
+func (d *inProcessAuctionDistributor) holdStopAuctions(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
+	stopAuctionRequests := buildStopAuctionRequests(stopAuctions, repAddresses)
+	groupedRequests := groupStopAuctionRequests(stopAuctionRequests, numAuctioneers, d.partitionStrategy)
+
+	recorder := newOutcomeRecorder()
+	for i, requests := range groupedRequests {
+		recorder.AssignStopAuctions(inProcessAuctioneerName(i), requests)
+	}
+
+	results := []auctiontypes.StopAuctionResult{}
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(groupedRequests))
+	for i := range groupedRequests {
+		go func(auctioneer string, requests []ScheduledStopAuctionRequest) {
+			defer wg.Done()
+			runner := auctionrunner.New(d.repClient)
+			workers := workpool.NewWorkPool(d.maxConcurrent)
+
+			auctioneerWG := &sync.WaitGroup{}
+			ReleaseStopAuctionsOnArrival(ctx, time.Now(), requests, func(request ScheduledStopAuctionRequest) {
+				auctioneerWG.Add(1)
+				workers.Submit(func() {
+					defer auctioneerWG.Done()
+					if ctx.Err() != nil {
+						return
+					}
+					result, err := runner.RunLRPStopAuction(request.StopAuctionRequest)
+					if err != nil {
+						recorder.Fail(auctioneer, AuctionFailure, 0, err.Error())
+					}
+					lock.Lock()
+					results = append(results, result)
+					lock.Unlock()
+				})
+			})
+
+			auctioneerWG.Wait()
+			workers.Stop()
+		}(inProcessAuctioneerName(i), scheduleStopAuctionRequests(groupedRequests[i], arrivals))
+	}
+
+	wg.Wait()
+	return results, recorder.StopOutcome(stopAuctions, results, ctx.Err() != nil)
+}
+
+func inProcessAuctioneerName(index int) string {
+	return fmt.Sprintf("in-process-auctioneer-%d", index)
+}
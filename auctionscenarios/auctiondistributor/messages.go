@@ -0,0 +1,94 @@
+package auctiondistributor
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+func StartAuctionsSubject(auctioneerGuid string) string {
+	return auctioneerGuid + ".start-auctions"
+}
+
+func StopAuctionsSubject(auctioneerGuid string) string {
+	return auctioneerGuid + ".stop-auctions"
+}
+
+// CancelAuctionsSubject takes a CancelAuctionsMessage.
+func CancelAuctionsSubject(auctioneerGuid string) string {
+	return auctioneerGuid + ".cancel-auctions"
+}
+
+// The NATS messages carry the rep addresses and rules once per batch rather
+// than once per request to stay well under the NATS payload limit.
+type StartAuctionsMessage struct {
+	Mode          string
+	MaxConcurrent int
+	RepAddresses  []auctiontypes.RepAddress
+	Rules         auctiontypes.StartAuctionRules
+	StartAuctions []models.LRPStartAuction
+
+	ArrivalOffsets []time.Duration
+}
+
+func (m StartAuctionsMessage) Requests() []ScheduledStartAuctionRequest {
+	requests := []ScheduledStartAuctionRequest{}
+	for i, request := range buildStartAuctionRequests(m.StartAuctions, m.RepAddresses, m.Rules) {
+		scheduledRequest := ScheduledStartAuctionRequest{StartAuctionRequest: request}
+		if i < len(m.ArrivalOffsets) {
+			scheduledRequest.ArrivalOffset = m.ArrivalOffsets[i]
+		}
+		requests = append(requests, scheduledRequest)
+	}
+	return requests
+}
+
+// ScheduledStartAuctionRequest marshals to a plain StartAuctionRequest with
+// an extra ArrivalOffset field, so an auctioneer that is handed plain
+// requests treats them as a single burst.
+type ScheduledStartAuctionRequest struct {
+	auctiontypes.StartAuctionRequest
+	ArrivalOffset time.Duration
+}
+
+type StopAuctionsMessage struct {
+	Mode          string
+	MaxConcurrent int
+	RepAddresses  []auctiontypes.RepAddress
+	StopAuctions  []models.LRPStopAuction
+
+	ArrivalOffsets []time.Duration
+}
+
+func (m StopAuctionsMessage) Requests() []ScheduledStopAuctionRequest {
+	requests := []ScheduledStopAuctionRequest{}
+	for i, request := range buildStopAuctionRequests(m.StopAuctions, m.RepAddresses) {
+		scheduledRequest := ScheduledStopAuctionRequest{StopAuctionRequest: request}
+		if i < len(m.ArrivalOffsets) {
+			scheduledRequest.ArrivalOffset = m.ArrivalOffsets[i]
+		}
+		requests = append(requests, scheduledRequest)
+	}
+	return requests
+}
+
+type ScheduledStopAuctionRequest struct {
+	auctiontypes.StopAuctionRequest
+	ArrivalOffset time.Duration
+}
+
+// A run over NATS is identified by the subject its results are published on.
+type CancelAuctionsMessage struct {
+	ReplyTo string
+}
+
+type StartAuctionsJob struct {
+	JobID       string
+	BatchID     string
+	NumAuctions int
+	NumResults  int
+	Done        bool
+	Cancelled   bool
+	CreatedAt   time.Time
+}
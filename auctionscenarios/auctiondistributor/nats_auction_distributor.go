@@ -0,0 +1,259 @@
+package auctiondistributor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/yagnats"
+)
+
+var replySubjectCounter int64
+
+type natsAuctionDistributor struct {
+	natsClient               yagnats.NATSClient
+	auctioneerGuids          []string
+	auctionCommunicationMode string
+	maxConcurrent            int
+	partitionStrategy        PartitionStrategy
+	arrivalSchedule          ArrivalSchedule
+}
+
+func NewNATSAuctionDistributor(natsClient yagnats.NATSClient, auctioneerGuids []string, maxConcurrent int, auctionCommunicationMode string, partitionStrategy PartitionStrategy, arrivalSchedule ArrivalSchedule) AuctionDistributor {
+	return &natsAuctionDistributor{
+		natsClient:               natsClient,
+		auctioneerGuids:          auctioneerGuids,
+		auctionCommunicationMode: auctionCommunicationMode,
+		maxConcurrent:            maxConcurrent,
+		partitionStrategy:        partitionStrategy,
+		arrivalSchedule:          arrivalSchedule,
+	}
+}
+
+func (d *natsAuctionDistributor) HoldStartAuctions(numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) []auctiontypes.StartAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStartAuctionsWithContext(ctx, numAuctioneers, startAuctions, repAddresses, rules)
+	return results
+}
+
+func (d *natsAuctionDistributor) HoldStartAuctionsWithContext(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
+	return d.holdStartAuctions(ctx, numAuctioneers, startAuctions, startAuctionArrivals(startAuctions, d.arrivalSchedule), repAddresses, rules)
+}
+
+func (d *natsAuctionDistributor) holdStartAuctions(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome) {
+	if len(startAuctions) == 0 {
+		return []auctiontypes.StartAuctionResult{}, RunOutcome{Complete: true}
+	}
+
+	startAuctionRequests := buildStartAuctionRequests(startAuctions, repAddresses, rules)
+	groupedRequests := groupStartAuctionRequests(startAuctionRequests, numAuctioneers, d.partitionStrategy)
+
+	bar := pb.StartNew(len(startAuctions))
+
+	recorder := newOutcomeRecorder()
+	for i, requests := range groupedRequests {
+		recorder.AssignStartAuctions(d.auctioneerGuids[i], requests)
+	}
+
+	results := []auctiontypes.StartAuctionResult{}
+	lock := &sync.Mutex{}
+	allReceived := make(chan struct{})
+	// Groups that fail to publish never reply, so the run stops waiting for them.
+	nExpected := len(startAuctions)
+	closeAllReceived := &sync.Once{}
+	checkAllReceived := func() {
+		if len(results) >= nExpected {
+			closeAllReceived.Do(func() { close(allReceived) })
+		}
+	}
+
+	replyTo := newReplySubject("start-auctions")
+	sid, err := d.natsClient.Subscribe(replyTo, func(msg *yagnats.Message) {
+		result := auctiontypes.StartAuctionResult{}
+		err := json.Unmarshal(msg.Payload, &result)
+		if err != nil {
+			fmt.Println("Failed to decode start auction result", err.Error(), string(msg.Payload))
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		results = append(results, result)
+		bar.Set(len(results))
+		checkAllReceived()
+	})
+	if err != nil {
+		fmt.Println("Failed to subscribe to", replyTo, err.Error())
+		return results, recorder.StartOutcome(startAuctions, results, false)
+	}
+	defer d.natsClient.Unsubscribe(sid)
+
+	published := []int{}
+	for i, requests := range groupedRequests {
+		message := StartAuctionsMessage{
+			Mode:          d.auctionCommunicationMode,
+			MaxConcurrent: d.maxConcurrent,
+			RepAddresses:  repAddresses,
+			Rules:         rules,
+		}
+		for _, request := range scheduleStartAuctionRequests(requests, arrivals) {
+			message.StartAuctions = append(message.StartAuctions, request.LRPStartAuction)
+			message.ArrivalOffsets = append(message.ArrivalOffsets, request.ArrivalOffset)
+		}
+
+		payload, _ := json.Marshal(message)
+		err := d.natsClient.PublishWithReplyTo(StartAuctionsSubject(d.auctioneerGuids[i]), replyTo, payload)
+		if err != nil {
+			fmt.Println("Failed to publish auctions on index", i, err.Error())
+			recorder.Fail(d.auctioneerGuids[i], PublishFailure, 0, err.Error())
+
+			lock.Lock()
+			nExpected -= len(requests)
+			checkAllReceived()
+			lock.Unlock()
+			continue
+		}
+		published = append(published, i)
+	}
+
+	select {
+	case <-allReceived:
+	case <-ctx.Done():
+		d.cancelRun(replyTo, published)
+	}
+
+	bar.Finish()
+
+	lock.Lock()
+	defer lock.Unlock()
+	results = append([]auctiontypes.StartAuctionResult{}, results...)
+	return results, recorder.StartOutcome(startAuctions, results, ctx.Err() != nil)
+}
+
+func (d *natsAuctionDistributor) HoldWorkload(numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) WorkloadResults {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldWorkloadWithContext(ctx, numAuctioneers, workload, repAddresses, rules)
+	return results
+}
+
+func (d *natsAuctionDistributor) HoldWorkloadWithContext(ctx context.Context, numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) (WorkloadResults, RunOutcome) {
+	return holdWorkload(ctx, d, d.arrivalSchedule, numAuctioneers, workload, repAddresses, rules)
+}
+
+func (d *natsAuctionDistributor) HoldStopAuctions(numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) []auctiontypes.StopAuctionResult {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAuctionTimeout)
+	defer cancel()
+	results, _ := d.HoldStopAuctionsWithContext(ctx, numAuctioneers, stopAuctions, repAddresses)
+	return results
+}
+
+func (d *natsAuctionDistributor) HoldStopAuctionsWithContext(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
+	return d.holdStopAuctions(ctx, numAuctioneers, stopAuctions, nil, repAddresses)
+}
+
+func (d *natsAuctionDistributor) holdStopAuctions(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome) {
+	if len(stopAuctions) == 0 {
+		return []auctiontypes.StopAuctionResult{}, RunOutcome{Complete: true}
+	}
+
+	stopAuctionRequests := buildStopAuctionRequests(stopAuctions, repAddresses)
+	groupedRequests := groupStopAuctionRequests(stopAuctionRequests, numAuctioneers, d.partitionStrategy)
+
+	recorder := newOutcomeRecorder()
+	for i, requests := range groupedRequests {
+		recorder.AssignStopAuctions(d.auctioneerGuids[i], requests)
+	}
+
+	results := []auctiontypes.StopAuctionResult{}
+	lock := &sync.Mutex{}
+	allReceived := make(chan struct{})
+	nExpected := len(stopAuctions)
+	closeAllReceived := &sync.Once{}
+	checkAllReceived := func() {
+		if len(results) >= nExpected {
+			closeAllReceived.Do(func() { close(allReceived) })
+		}
+	}
+
+	replyTo := newReplySubject("stop-auctions")
+	sid, err := d.natsClient.Subscribe(replyTo, func(msg *yagnats.Message) {
+		result := auctiontypes.StopAuctionResult{}
+		err := json.Unmarshal(msg.Payload, &result)
+		if err != nil {
+			fmt.Println("Failed to decode stop auction result", err.Error(), string(msg.Payload))
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		results = append(results, result)
+		checkAllReceived()
+	})
+	if err != nil {
+		fmt.Println("Failed to subscribe to", replyTo, err.Error())
+		return results, recorder.StopOutcome(stopAuctions, results, false)
+	}
+	defer d.natsClient.Unsubscribe(sid)
+
+	published := []int{}
+	for i, requests := range groupedRequests {
+		message := StopAuctionsMessage{
+			Mode:          d.auctionCommunicationMode,
+			MaxConcurrent: d.maxConcurrent,
+			RepAddresses:  repAddresses,
+		}
+		for _, request := range scheduleStopAuctionRequests(requests, arrivals) {
+			message.StopAuctions = append(message.StopAuctions, request.LRPStopAuction)
+			message.ArrivalOffsets = append(message.ArrivalOffsets, request.ArrivalOffset)
+		}
+
+		payload, _ := json.Marshal(message)
+		err := d.natsClient.PublishWithReplyTo(StopAuctionsSubject(d.auctioneerGuids[i]), replyTo, payload)
+		if err != nil {
+			fmt.Println("Failed to publish auctions on index", i, err.Error())
+			recorder.Fail(d.auctioneerGuids[i], PublishFailure, 0, err.Error())
+
+			lock.Lock()
+			nExpected -= len(requests)
+			checkAllReceived()
+			lock.Unlock()
+			continue
+		}
+		published = append(published, i)
+	}
+
+	select {
+	case <-allReceived:
+	case <-ctx.Done():
+		d.cancelRun(replyTo, published)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	results = append([]auctiontypes.StopAuctionResult{}, results...)
+	return results, recorder.StopOutcome(stopAuctions, results, ctx.Err() != nil)
+}
+
+// cancelRun tells every auctioneer that was handed part of the run to stop
+// working on it.
+func (d *natsAuctionDistributor) cancelRun(replyTo string, published []int) {
+	payload, _ := json.Marshal(CancelAuctionsMessage{ReplyTo: replyTo})
+	for _, i := range published {
+		err := d.natsClient.Publish(CancelAuctionsSubject(d.auctioneerGuids[i]), payload)
+		if err != nil {
+			fmt.Println("Failed to cancel auctions on index", i, err.Error())
+		}
+	}
+}
+
+func newReplySubject(kind string) string {
+	return fmt.Sprintf("auction-distributor.%s.%d.%d", kind, time.Now().UnixNano(), atomic.AddInt64(&replySubjectCounter, 1))
+}
@@ -0,0 +1,127 @@
+package auctiondistributor
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	RoundRobinPartitioning = "round-robin"
+	ContiguousPartitioning = "contiguous"
+	HashPartitioning       = "hash-by-process-guid"
+	WeightedPartitioning   = "weighted"
+)
+
+var PartitionStrategies = []string{RoundRobinPartitioning, ContiguousPartitioning, HashPartitioning, WeightedPartitioning}
+
+// A PartitionStrategy decides which auctioneer holds each auction.  It is
+// handed the ProcessGuid of every auction, in order, and returns the index
+// of the auctioneer for each.
+type PartitionStrategy interface {
+	Name() string
+	Partition(processGuids []string, numAuctioneers int) []int
+}
+
+// NewPartitionStrategy looks a strategy up by name.  The weights are only
+// used by the weighted strategy; auctioneer i gets weights[i % len(weights)].
+func NewPartitionStrategy(name string, weights []float64) (PartitionStrategy, error) {
+	switch name {
+	case RoundRobinPartitioning:
+		return roundRobinPartitionStrategy{}, nil
+	case ContiguousPartitioning:
+		return contiguousPartitionStrategy{}, nil
+	case HashPartitioning:
+		return hashPartitionStrategy{}, nil
+	case WeightedPartitioning:
+		if len(weights) == 0 {
+			return nil, fmt.Errorf("the %s partition strategy needs auctioneer weights", name)
+		}
+		for _, weight := range weights {
+			if weight <= 0 {
+				return nil, fmt.Errorf("auctioneer weights must be positive, got %f", weight)
+			}
+		}
+		return weightedPartitionStrategy{weights: weights}, nil
+	default:
+		return nil, fmt.Errorf("unknown partition strategy: %s", name)
+	}
+}
+
+type roundRobinPartitionStrategy struct{}
+
+func (roundRobinPartitionStrategy) Name() string {
+	return RoundRobinPartitioning
+}
+
+func (roundRobinPartitionStrategy) Partition(processGuids []string, numAuctioneers int) []int {
+	indices := make([]int, len(processGuids))
+	for i := range processGuids {
+		indices[i] = i % numAuctioneers
+	}
+	return indices
+}
+
+type contiguousPartitionStrategy struct{}
+
+func (contiguousPartitionStrategy) Name() string {
+	return ContiguousPartitioning
+}
+
+func (contiguousPartitionStrategy) Partition(processGuids []string, numAuctioneers int) []int {
+	indices := make([]int, len(processGuids))
+	for i := range processGuids {
+		indices[i] = i * numAuctioneers / len(processGuids)
+	}
+	return indices
+}
+
+type hashPartitionStrategy struct{}
+
+func (hashPartitionStrategy) Name() string {
+	return HashPartitioning
+}
+
+func (hashPartitionStrategy) Partition(processGuids []string, numAuctioneers int) []int {
+	indices := make([]int, len(processGuids))
+	for i, processGuid := range processGuids {
+		h := fnv.New32a()
+		h.Write([]byte(processGuid))
+		indices[i] = int(h.Sum32() % uint32(numAuctioneers))
+	}
+	return indices
+}
+
+// weightedPartitionStrategy uses smooth weighted round-robin so that heavier
+// auctioneers get proportionally more auctions without receiving them in
+// long runs.
+type weightedPartitionStrategy struct {
+	weights []float64
+}
+
+func (weightedPartitionStrategy) Name() string {
+	return WeightedPartitioning
+}
+
+func (s weightedPartitionStrategy) Partition(processGuids []string, numAuctioneers int) []int {
+	weights := make([]float64, numAuctioneers)
+	totalWeight := 0.0
+	for i := range weights {
+		weights[i] = s.weights[i%len(s.weights)]
+		totalWeight += weights[i]
+	}
+
+	current := make([]float64, numAuctioneers)
+	indices := make([]int, len(processGuids))
+	for i := range processGuids {
+		best := 0
+		for j := range current {
+			current[j] += weights[j]
+			if current[j] > current[best] {
+				best = j
+			}
+		}
+		current[best] -= totalWeight
+		indices[i] = best
+	}
+	return indices
+}
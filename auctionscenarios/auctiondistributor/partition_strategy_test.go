@@ -0,0 +1,66 @@
+package auctiondistributor_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+var _ = Describe("PartitionStrategy", func() {
+	processGuids := []string{"a", "b", "c", "d", "e", "f"}
+
+	partition := func(name string, weights []float64, numAuctioneers int) []int {
+		strategy, err := auctiondistributor.NewPartitionStrategy(name, weights)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(strategy.Name()).Should(Equal(name))
+		return strategy.Partition(processGuids, numAuctioneers)
+	}
+
+	It("deals round-robin auctions out in turn", func() {
+		Ω(partition(auctiondistributor.RoundRobinPartitioning, nil, 4)).Should(Equal([]int{0, 1, 2, 3, 0, 1}))
+	})
+
+	It("gives contiguous runs of auctions to each auctioneer", func() {
+		Ω(partition(auctiondistributor.ContiguousPartitioning, nil, 4)).Should(Equal([]int{0, 0, 1, 2, 2, 3}))
+		Ω(partition(auctiondistributor.ContiguousPartitioning, nil, 12)).Should(Equal([]int{0, 2, 4, 6, 8, 10}))
+	})
+
+	It("hashes every instance of a process to the same auctioneer", func() {
+		strategy, err := auctiondistributor.NewPartitionStrategy(auctiondistributor.HashPartitioning, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		indices := strategy.Partition([]string{"a", "b", "a", "c", "b", "a"}, 3)
+		Ω(indices).Should(HaveLen(6))
+		Ω(indices[2]).Should(Equal(indices[0]))
+		Ω(indices[5]).Should(Equal(indices[0]))
+		Ω(indices[4]).Should(Equal(indices[1]))
+		for _, index := range indices {
+			Ω(index).Should(BeNumerically(">=", 0))
+			Ω(index).Should(BeNumerically("<", 3))
+		}
+	})
+
+	It("spreads weighted auctions in proportion to the weights, repeating them across auctioneers", func() {
+		Ω(partition(auctiondistributor.WeightedPartitioning, []float64{2, 1}, 2)).Should(Equal([]int{0, 1, 0, 0, 1, 0}))
+		Ω(partition(auctiondistributor.WeightedPartitioning, []float64{1}, 3)).Should(Equal([]int{0, 1, 2, 0, 1, 2}))
+
+		strategy, err := auctiondistributor.NewPartitionStrategy(auctiondistributor.WeightedPartitioning, []float64{3, 1})
+		Ω(err).ShouldNot(HaveOccurred())
+		counts := map[int]int{}
+		for _, index := range strategy.Partition(make([]string, 400), 2) {
+			counts[index]++
+		}
+		Ω(counts).Should(Equal(map[int]int{0: 300, 1: 100}))
+	})
+
+	It("rejects unknown strategies and weights that aren't all positive", func() {
+		_, err := auctiondistributor.NewPartitionStrategy("random", nil)
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewPartitionStrategy(auctiondistributor.WeightedPartitioning, nil)
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewPartitionStrategy(auctiondistributor.WeightedPartitioning, []float64{1, 0})
+		Ω(err).Should(HaveOccurred())
+		_, err = auctiondistributor.NewPartitionStrategy(auctiondistributor.WeightedPartitioning, []float64{-1})
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,39 @@
+package auctiondistributor
+
+import (
+	"fmt"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	AttemptTimeout time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	AttemptTimeout: 10 * time.Second,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("a batch needs at least one attempt, got %d", p.MaxAttempts)
+	}
+	return nil
+}
+
+// Backoff returns how long to wait after the given (1-based) failed attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,39 @@
+package auctiondistributor_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	It("doubles the backoff after every attempt, up to MaxBackoff", func() {
+		policy := auctiondistributor.RetryPolicy{
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+		}
+		Ω(policy.Backoff(1)).Should(Equal(500 * time.Millisecond))
+		Ω(policy.Backoff(2)).Should(Equal(time.Second))
+		Ω(policy.Backoff(4)).Should(Equal(4 * time.Second))
+		Ω(policy.Backoff(5)).Should(Equal(5 * time.Second))
+		Ω(policy.Backoff(50)).Should(Equal(5 * time.Second))
+	})
+
+	It("caps an initial backoff above MaxBackoff", func() {
+		policy := auctiondistributor.RetryPolicy{
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     5 * time.Second,
+		}
+		Ω(policy.Backoff(1)).Should(Equal(5 * time.Second))
+	})
+
+	It("needs at least one attempt", func() {
+		Ω(auctiondistributor.DefaultRetryPolicy.Validate()).ShouldNot(HaveOccurred())
+
+		policy := auctiondistributor.DefaultRetryPolicy
+		policy.MaxAttempts = 0
+		Ω(policy.Validate()).Should(HaveOccurred())
+	})
+})
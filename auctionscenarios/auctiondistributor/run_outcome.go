@@ -0,0 +1,286 @@
+package auctiondistributor
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+type FailureClass string
+
+const (
+	ConnectionFailure FailureClass = "connection"
+	TimeoutFailure    FailureClass = "timeout"
+	StatusFailure     FailureClass = "status"
+	DecodeFailure     FailureClass = "decode"
+	PublishFailure    FailureClass = "publish"
+	AuctionFailure    FailureClass = "auction"
+)
+
+// AuctioneerFailure aggregates every failure of one class (and, for
+// StatusFailure, one status code) seen while talking to an auctioneer.
+type AuctioneerFailure struct {
+	Class       FailureClass
+	StatusCode  int
+	Count       int
+	LastMessage string
+}
+
+// NPossiblyDuplicated counts requests that were handed to other auctioneers
+// without knowing whether this one had accepted them, so they may have run
+// twice.
+type AuctioneerOutcome struct {
+	Auctioneer          string
+	NRequests           int
+	NUnacknowledged     int
+	NPossiblyDuplicated int
+	Failures            []AuctioneerFailure
+}
+
+// RunOutcome describes the infrastructure side of a run: which auctioneers
+// misbehaved and which auctions never came back.  An auction that ran but
+// failed to place its instance is reported in the results, not here.
+type RunOutcome struct {
+	Complete          bool
+	Auctioneers       []AuctioneerOutcome
+	LostStartAuctions []models.LRPStartAuction
+	LostStopAuctions  []models.LRPStopAuction
+}
+
+func (o RunOutcome) NFailedAuctioneers() int {
+	return len(o.Auctioneers)
+}
+
+func (o RunOutcome) NLostAuctions() int {
+	return len(o.LostStartAuctions) + len(o.LostStopAuctions)
+}
+
+func (o RunOutcome) NPossibleDuplicates() int {
+	n := 0
+	for _, auctioneer := range o.Auctioneers {
+		n += auctioneer.NPossiblyDuplicated
+	}
+	return n
+}
+
+func (o RunOutcome) HasInfrastructureFailures() bool {
+	return o.NFailedAuctioneers() > 0 || o.NLostAuctions() > 0
+}
+
+func classifyError(err error) FailureClass {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return TimeoutFailure
+	}
+	return ConnectionFailure
+}
+
+func startAuctionKey(startAuction models.LRPStartAuction) string {
+	return startAuction.InstanceGuid
+}
+
+func stopAuctionKey(stopAuction models.LRPStopAuction) string {
+	return fmt.Sprintf("%s.%d", stopAuction.ProcessGuid, stopAuction.Index)
+}
+
+type outcomeRecorder struct {
+	lock        *sync.Mutex
+	assignments map[string]string
+	nRequests   map[string]int
+	nAbandoned  map[string]int
+	nSuspected  map[string]int
+	failures    map[string][]AuctioneerFailure
+}
+
+func newOutcomeRecorder() *outcomeRecorder {
+	return &outcomeRecorder{
+		lock:        &sync.Mutex{},
+		assignments: map[string]string{},
+		nRequests:   map[string]int{},
+		nAbandoned:  map[string]int{},
+		nSuspected:  map[string]int{},
+		failures:    map[string][]AuctioneerFailure{},
+	}
+}
+
+func (r *outcomeRecorder) AssignStartAuctions(auctioneer string, requests []auctiontypes.StartAuctionRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, request := range requests {
+		r.assignments[startAuctionKey(request.LRPStartAuction)] = auctioneer
+	}
+	r.nRequests[auctioneer] += len(requests)
+}
+
+func (r *outcomeRecorder) AssignStopAuctions(auctioneer string, requests []auctiontypes.StopAuctionRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, request := range requests {
+		r.assignments[stopAuctionKey(request.LRPStopAuction)] = auctioneer
+	}
+	r.nRequests[auctioneer] += len(requests)
+}
+
+// Abandon records requests that an auctioneer never acknowledged and that
+// were handed to other auctioneers instead.
+func (r *outcomeRecorder) Abandon(auctioneer string, n int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nAbandoned[auctioneer] += n
+}
+
+// SuspectDuplicates records abandoned requests that the auctioneer may have
+// accepted after all.
+func (r *outcomeRecorder) SuspectDuplicates(auctioneer string, n int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nSuspected[auctioneer] += n
+}
+
+func (r *outcomeRecorder) Fail(auctioneer string, class FailureClass, statusCode int, message string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	failures := r.failures[auctioneer]
+	for i := range failures {
+		if failures[i].Class == class && failures[i].StatusCode == statusCode {
+			failures[i].Count++
+			failures[i].LastMessage = message
+			return
+		}
+	}
+	r.failures[auctioneer] = append(failures, AuctioneerFailure{
+		Class:       class,
+		StatusCode:  statusCode,
+		Count:       1,
+		LastMessage: message,
+	})
+}
+
+func (r *outcomeRecorder) FailWithError(auctioneer string, err error) {
+	r.Fail(auctioneer, classifyError(err), 0, err.Error())
+}
+
+func (r *outcomeRecorder) StartOutcome(startAuctions []models.LRPStartAuction, results []auctiontypes.StartAuctionResult, cancelled bool) RunOutcome {
+	received := map[string]bool{}
+	for _, result := range results {
+		received[startAuctionKey(result.LRPStartAuction)] = true
+	}
+
+	outcome := RunOutcome{}
+	for _, startAuction := range startAuctions {
+		if !received[startAuctionKey(startAuction)] {
+			outcome.LostStartAuctions = append(outcome.LostStartAuctions, startAuction)
+		}
+	}
+	outcome.Auctioneers = r.auctioneerOutcomes(received)
+	outcome.Complete = !cancelled && len(outcome.LostStartAuctions) == 0
+
+	return outcome
+}
+
+func (r *outcomeRecorder) StopOutcome(stopAuctions []models.LRPStopAuction, results []auctiontypes.StopAuctionResult, cancelled bool) RunOutcome {
+	received := map[string]bool{}
+	for _, result := range results {
+		received[stopAuctionKey(result.LRPStopAuction)] = true
+	}
+
+	outcome := RunOutcome{}
+	for _, stopAuction := range stopAuctions {
+		if !received[stopAuctionKey(stopAuction)] {
+			outcome.LostStopAuctions = append(outcome.LostStopAuctions, stopAuction)
+		}
+	}
+	outcome.Auctioneers = r.auctioneerOutcomes(received)
+	outcome.Complete = !cancelled && len(outcome.LostStopAuctions) == 0
+
+	return outcome
+}
+
+func (r *outcomeRecorder) auctioneerOutcomes(received map[string]bool) []AuctioneerOutcome {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	nUnacknowledged := map[string]int{}
+	for auctioneer, n := range r.nAbandoned {
+		nUnacknowledged[auctioneer] += n
+	}
+	for key, auctioneer := range r.assignments {
+		if !received[key] {
+			nUnacknowledged[auctioneer]++
+		}
+	}
+
+	auctioneers := []string{}
+	for auctioneer := range r.nRequests {
+		if nUnacknowledged[auctioneer] > 0 || r.nSuspected[auctioneer] > 0 || len(r.failures[auctioneer]) > 0 {
+			auctioneers = append(auctioneers, auctioneer)
+		}
+	}
+	sort.Strings(auctioneers)
+
+	outcomes := []AuctioneerOutcome{}
+	for _, auctioneer := range auctioneers {
+		outcomes = append(outcomes, AuctioneerOutcome{
+			Auctioneer:          auctioneer,
+			NRequests:           r.nRequests[auctioneer],
+			NUnacknowledged:     nUnacknowledged[auctioneer],
+			NPossiblyDuplicated: r.nSuspected[auctioneer],
+			Failures:            r.failures[auctioneer],
+		})
+	}
+
+	return outcomes
+}
+
+// mergeOutcomes combines the outcomes of runs that shared auctioneers.
+func mergeOutcomes(a RunOutcome, b RunOutcome) RunOutcome {
+	merged := RunOutcome{
+		Complete:          a.Complete && b.Complete,
+		LostStartAuctions: append(append([]models.LRPStartAuction{}, a.LostStartAuctions...), b.LostStartAuctions...),
+		LostStopAuctions:  append(append([]models.LRPStopAuction{}, a.LostStopAuctions...), b.LostStopAuctions...),
+	}
+
+	byAuctioneer := map[string]*AuctioneerOutcome{}
+	auctioneers := []string{}
+	for _, outcome := range append(append([]AuctioneerOutcome{}, a.Auctioneers...), b.Auctioneers...) {
+		existing, ok := byAuctioneer[outcome.Auctioneer]
+		if !ok {
+			existing = &AuctioneerOutcome{Auctioneer: outcome.Auctioneer}
+			byAuctioneer[outcome.Auctioneer] = existing
+			auctioneers = append(auctioneers, outcome.Auctioneer)
+		}
+		existing.NRequests += outcome.NRequests
+		existing.NUnacknowledged += outcome.NUnacknowledged
+		existing.NPossiblyDuplicated += outcome.NPossiblyDuplicated
+		for _, failure := range outcome.Failures {
+			existing.Failures = mergeFailure(existing.Failures, failure)
+		}
+	}
+	sort.Strings(auctioneers)
+
+	merged.Auctioneers = []AuctioneerOutcome{}
+	for _, auctioneer := range auctioneers {
+		merged.Auctioneers = append(merged.Auctioneers, *byAuctioneer[auctioneer])
+	}
+
+	return merged
+}
+
+func mergeFailure(failures []AuctioneerFailure, failure AuctioneerFailure) []AuctioneerFailure {
+	for i := range failures {
+		if failures[i].Class == failure.Class && failures[i].StatusCode == failure.StatusCode {
+			failures[i].Count += failure.Count
+			failures[i].LastMessage = failure.LastMessage
+			return failures
+		}
+	}
+	return append(failures, failure)
+}
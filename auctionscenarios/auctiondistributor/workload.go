@@ -0,0 +1,110 @@
+package auctiondistributor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+// A WorkloadItem holds exactly one of a start auction or a stop auction.
+type WorkloadItem struct {
+	StartAuction *models.LRPStartAuction `json:",omitempty"`
+	StopAuction  *models.LRPStopAuction  `json:",omitempty"`
+}
+
+type Workload []WorkloadItem
+
+// InterleaveWorkload spreads the stop auctions evenly through the start
+// auctions.
+func InterleaveWorkload(startAuctions []models.LRPStartAuction, stopAuctions []models.LRPStopAuction) Workload {
+	workload := Workload{}
+	total := len(startAuctions) + len(stopAuctions)
+	nextStart, nextStop := 0, 0
+	for i := 0; i < total; i++ {
+		if nextStop < len(stopAuctions) && (nextStart == len(startAuctions) || (2*nextStop+1)*total <= (2*i+1)*len(stopAuctions)) {
+			workload = append(workload, WorkloadItem{StopAuction: &stopAuctions[nextStop]})
+			nextStop++
+		} else {
+			workload = append(workload, WorkloadItem{StartAuction: &startAuctions[nextStart]})
+			nextStart++
+		}
+	}
+	return workload
+}
+
+func (w Workload) StartAuctions() []models.LRPStartAuction {
+	startAuctions := []models.LRPStartAuction{}
+	for _, item := range w {
+		if item.StartAuction != nil {
+			startAuctions = append(startAuctions, *item.StartAuction)
+		}
+	}
+	return startAuctions
+}
+
+func (w Workload) StopAuctions() []models.LRPStopAuction {
+	stopAuctions := []models.LRPStopAuction{}
+	for _, item := range w {
+		if item.StopAuction != nil {
+			stopAuctions = append(stopAuctions, *item.StopAuction)
+		}
+	}
+	return stopAuctions
+}
+
+type WorkloadResults struct {
+	StartAuctionResults []auctiontypes.StartAuctionResult
+	StopAuctionResults  []auctiontypes.StopAuctionResult
+}
+
+// scheduledAuctionDistributor holds auctions at arrival offsets chosen by the
+// caller, keyed like the outcome recorder's.  Auctions without an offset
+// arrive straight away.
+type scheduledAuctionDistributor interface {
+	holdStartAuctions(ctx context.Context, numAuctioneers int, startAuctions []models.LRPStartAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) ([]auctiontypes.StartAuctionResult, RunOutcome)
+	holdStopAuctions(ctx context.Context, numAuctioneers int, stopAuctions []models.LRPStopAuction, arrivals map[string]time.Duration, repAddresses []auctiontypes.RepAddress) ([]auctiontypes.StopAuctionResult, RunOutcome)
+}
+
+// holdWorkload runs the start and stop auctions of a workload at the same
+// time, so that stop auctions race with placement just as they do in a real
+// deployment.  The arrival schedule is laid over the whole workload, so each
+// auction arrives at the offset of its position in the interleaved sequence;
+// with the burst schedule everything arrives at once.
+func holdWorkload(ctx context.Context, d scheduledAuctionDistributor, schedule ArrivalSchedule, numAuctioneers int, workload Workload, repAddresses []auctiontypes.RepAddress, rules auctiontypes.StartAuctionRules) (WorkloadResults, RunOutcome) {
+	startArrivals, stopArrivals := workloadArrivals(workload, schedule)
+
+	results := WorkloadResults{}
+	var startOutcome, stopOutcome RunOutcome
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		results.StartAuctionResults, startOutcome = d.holdStartAuctions(ctx, numAuctioneers, workload.StartAuctions(), startArrivals, repAddresses, rules)
+	}()
+	go func() {
+		defer wg.Done()
+		results.StopAuctionResults, stopOutcome = d.holdStopAuctions(ctx, numAuctioneers, workload.StopAuctions(), stopArrivals, repAddresses)
+	}()
+	wg.Wait()
+
+	return results, mergeOutcomes(startOutcome, stopOutcome)
+}
+
+// workloadArrivals gives every auction of the workload the arrival of its
+// position.
+func workloadArrivals(workload Workload, schedule ArrivalSchedule) (map[string]time.Duration, map[string]time.Duration) {
+	startArrivals := map[string]time.Duration{}
+	stopArrivals := map[string]time.Duration{}
+	for i, arrival := range schedule.Arrivals(len(workload)) {
+		if workload[i].StartAuction != nil {
+			startArrivals[startAuctionKey(*workload[i].StartAuction)] = arrival
+		} else {
+			stopArrivals[stopAuctionKey(*workload[i].StopAuction)] = arrival
+		}
+	}
+	return startArrivals, stopArrivals
+}
@@ -0,0 +1,82 @@
+package auctiondistributor_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+var _ = Describe("Workload", func() {
+	startAuctions := func(n int) []models.LRPStartAuction {
+		startAuctions := []models.LRPStartAuction{}
+		for i := 0; i < n; i++ {
+			startAuctions = append(startAuctions, models.LRPStartAuction{InstanceGuid: fmt.Sprintf("start-%d", i), Index: i})
+		}
+		return startAuctions
+	}
+
+	stopAuctions := func(n int) []models.LRPStopAuction {
+		stopAuctions := []models.LRPStopAuction{}
+		for i := 0; i < n; i++ {
+			stopAuctions = append(stopAuctions, models.LRPStopAuction{ProcessGuid: "stop", Index: i})
+		}
+		return stopAuctions
+	}
+
+	// + is a start auction, - a stop auction
+	pattern := func(workload auctiondistributor.Workload) string {
+		s := ""
+		for _, item := range workload {
+			if item.StartAuction != nil {
+				s += "+"
+			} else {
+				s += "-"
+			}
+		}
+		return s
+	}
+
+	interleave := func(nStarts int, nStops int) string {
+		workload := auctiondistributor.InterleaveWorkload(startAuctions(nStarts), stopAuctions(nStops))
+		Ω(workload.StartAuctions()).Should(Equal(startAuctions(nStarts)))
+		Ω(workload.StopAuctions()).Should(Equal(stopAuctions(nStops)))
+		return pattern(workload)
+	}
+
+	It("spreads the stop auctions evenly through the start auctions", func() {
+		Ω(interleave(4, 2)).Should(Equal("+-++-+"))
+		Ω(interleave(6, 2)).Should(Equal("++-+++-+"))
+		Ω(interleave(5, 1)).Should(Equal("+++-++"))
+	})
+
+	It("spreads the start auctions evenly through the stop auctions when there are more stops", func() {
+		Ω(interleave(2, 4)).Should(Equal("+--+--"))
+	})
+
+	It("keeps a workload of one kind as it is", func() {
+		Ω(interleave(3, 0)).Should(Equal("+++"))
+		Ω(interleave(0, 2)).Should(Equal("--"))
+	})
+
+	It("gives each auction the arrival of its position in the workload", func() {
+		workload := auctiondistributor.InterleaveWorkload(startAuctions(4), stopAuctions(2))
+		schedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.ConstantArrivals, 1, "")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		startArrivals, stopArrivals := auctiondistributor.WorkloadArrivals(workload, schedule)
+		Ω(startArrivals).Should(Equal(map[string]time.Duration{
+			"start-0": 0,
+			"start-1": 2 * time.Second,
+			"start-2": 3 * time.Second,
+			"start-3": 5 * time.Second,
+		}))
+		Ω(stopArrivals).Should(Equal(map[string]time.Duration{
+			"stop.0": time.Second,
+			"stop.1": 4 * time.Second,
+		}))
+	})
+})
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+type startAuctionJob struct {
+	id          string
+	batchID     string
+	numAuctions int
+	createdAt   time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	lock       *sync.Mutex
+	results    []auctiontypes.StartAuctionResult
+	done       bool
+	finishedAt time.Time
+	cancelled  bool
+	changed    chan struct{}
+}
+
+func (j *startAuctionJob) Context() context.Context {
+	return j.ctx
+}
+
+func (j *startAuctionJob) Cancel() {
+	j.lock.Lock()
+	j.cancelled = true
+	j.lock.Unlock()
+	j.cancel()
+}
+
+func (j *startAuctionJob) AddResult(result auctiontypes.StartAuctionResult) {
+	j.lock.Lock()
+	j.results = append(j.results, result)
+	j.notify()
+	j.lock.Unlock()
+}
+
+func (j *startAuctionJob) Finish() {
+	j.lock.Lock()
+	j.done = true
+	j.finishedAt = time.Now()
+	j.notify()
+	j.lock.Unlock()
+}
+
+// Changed returns a channel that is closed the next time a result is added
+// or the job finishes.
+func (j *startAuctionJob) Changed() <-chan struct{} {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.changed
+}
+
+// finished returns when the job finished, if it has.
+func (j *startAuctionJob) finished() (time.Time, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.finishedAt, j.done
+}
+
+func (j *startAuctionJob) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *startAuctionJob) ResultsSince(offset int) ([]auctiontypes.StartAuctionResult, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if offset > len(j.results) {
+		offset = len(j.results)
+	}
+
+	return append([]auctiontypes.StartAuctionResult{}, j.results[offset:]...), j.done
+}
+
+func (j *startAuctionJob) Summary() auctiondistributor.StartAuctionsJob {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return auctiondistributor.StartAuctionsJob{
+		JobID:       j.id,
+		BatchID:     j.batchID,
+		NumAuctions: j.numAuctions,
+		NumResults:  len(j.results),
+		Done:        j.done,
+		Cancelled:   j.cancelled,
+		CreatedAt:   j.createdAt,
+	}
+}
+
+var errBatchForgotten = errors.New("the batch already ran and its results were forgotten")
+
+// jobRegistry forgets finished jobs once they are older than ttl, or once
+// there are more than maxFinished of them, oldest first, so that distributors
+// that never delete their jobs don't grow it forever.  A zero ttl or
+// maxFinished disables that limit.  The batch ids of forgotten jobs are kept,
+// so that a late retry of a batch isn't run again.
+type jobRegistry struct {
+	lock        *sync.RWMutex
+	jobs        map[string]*startAuctionJob
+	batches     map[string]string
+	forgotten   map[string]bool
+	counter     int
+	ttl         time.Duration
+	maxFinished int
+}
+
+func newJobRegistry(ttl time.Duration, maxFinished int) *jobRegistry {
+	return &jobRegistry{
+		lock:        &sync.RWMutex{},
+		jobs:        map[string]*startAuctionJob{},
+		batches:     map[string]string{},
+		forgotten:   map[string]bool{},
+		ttl:         ttl,
+		maxFinished: maxFinished,
+	}
+}
+
+// Create registers a new job.  Submitting the same (non-empty) batch id twice
+// returns the original job instead, so a retried submission is never run
+// twice, or errBatchForgotten once the original job has been forgotten.
+func (r *jobRegistry) Create(batchID string, numAuctions int) (*startAuctionJob, bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.prune()
+
+	if batchID != "" {
+		if job, ok := r.jobs[r.batches[batchID]]; ok {
+			return job, false, nil
+		}
+		if r.forgotten[batchID] {
+			return nil, false, errBatchForgotten
+		}
+	}
+
+	r.counter++
+	createdAt := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &startAuctionJob{
+		id:          fmt.Sprintf("job-%d-%d", createdAt.UnixNano(), r.counter),
+		batchID:     batchID,
+		numAuctions: numAuctions,
+		createdAt:   createdAt,
+		ctx:         ctx,
+		cancel:      cancel,
+		lock:        &sync.Mutex{},
+		results:     []auctiontypes.StartAuctionResult{},
+		changed:     make(chan struct{}),
+	}
+	r.jobs[job.id] = job
+	if batchID != "" {
+		r.batches[batchID] = job.id
+	}
+
+	return job, true, nil
+}
+
+func (r *jobRegistry) Get(id string) (*startAuctionJob, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+func (r *jobRegistry) FindBatch(batchID string) (*startAuctionJob, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	job, ok := r.jobs[r.batches[batchID]]
+	return job, ok
+}
+
+func (r *jobRegistry) Delete(id string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.delete(id)
+}
+
+func (r *jobRegistry) delete(id string) bool {
+	job, ok := r.jobs[id]
+	if ok {
+		job.cancel()
+		delete(r.batches, job.batchID)
+	}
+	delete(r.jobs, id)
+	return ok
+}
+
+// prune forgets the finished jobs that are past ttl or over maxFinished.
+func (r *jobRegistry) prune() {
+	finished := []finishedJob{}
+	for id, job := range r.jobs {
+		finishedAt, done := job.finished()
+		if !done {
+			continue
+		}
+		if r.ttl > 0 && time.Since(finishedAt) > r.ttl {
+			r.forget(id)
+			continue
+		}
+		finished = append(finished, finishedJob{id: id, finishedAt: finishedAt})
+	}
+
+	if r.maxFinished <= 0 || len(finished) <= r.maxFinished {
+		return
+	}
+	sort.Sort(byFinishedAt(finished))
+	for _, job := range finished[:len(finished)-r.maxFinished] {
+		r.forget(job.id)
+	}
+}
+
+func (r *jobRegistry) forget(id string) {
+	if batchID := r.jobs[id].batchID; batchID != "" {
+		r.forgotten[batchID] = true
+	}
+	r.delete(id)
+}
+
+func (r *jobRegistry) List() []auctiondistributor.StartAuctionsJob {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.prune()
+
+	summaries := []auctiondistributor.StartAuctionsJob{}
+	for _, job := range r.jobs {
+		summaries = append(summaries, job.Summary())
+	}
+	sort.Sort(byCreatedAt(summaries))
+
+	return summaries
+}
+
+type byCreatedAt []auctiondistributor.StartAuctionsJob
+
+func (b byCreatedAt) Len() int           { return len(b) }
+func (b byCreatedAt) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byCreatedAt) Less(i, j int) bool { return b[i].CreatedAt.Before(b[j].CreatedAt) }
+
+type finishedJob struct {
+	id         string
+	finishedAt time.Time
+}
+
+type byFinishedAt []finishedJob
+
+func (b byFinishedAt) Len() int           { return len(b) }
+func (b byFinishedAt) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byFinishedAt) Less(i, j int) bool { return b[i].finishedAt.Before(b[j].finishedAt) }
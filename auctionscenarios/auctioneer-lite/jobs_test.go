@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("jobRegistry", func() {
+	var registry *jobRegistry
+
+	BeforeEach(func() {
+		registry = newJobRegistry(0, 0)
+	})
+
+	jobIDs := func() []string {
+		ids := []string{}
+		for _, job := range registry.List() {
+			ids = append(ids, job.JobID)
+		}
+		return ids
+	}
+
+	Describe("Create", func() {
+		It("returns the original job when a batch is submitted again", func() {
+			job, created, _ := registry.Create("batch-1", 3)
+			Ω(created).Should(BeTrue())
+
+			again, created, _ := registry.Create("batch-1", 3)
+			Ω(created).Should(BeFalse())
+			Ω(again).Should(Equal(job))
+
+			found, ok := registry.FindBatch("batch-1")
+			Ω(ok).Should(BeTrue())
+			Ω(found).Should(Equal(job))
+			Ω(jobIDs()).Should(HaveLen(1))
+		})
+
+		It("never dedupes jobs without a batch id", func() {
+			first, created, _ := registry.Create("", 3)
+			Ω(created).Should(BeTrue())
+			second, created, _ := registry.Create("", 3)
+			Ω(created).Should(BeTrue())
+
+			Ω(second.id).ShouldNot(Equal(first.id))
+			_, ok := registry.FindBatch("")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("runs a batch again once its job is deleted", func() {
+			job, _, _ := registry.Create("batch-1", 3)
+			Ω(registry.Delete(job.id)).Should(BeTrue())
+			Ω(job.Context().Err()).Should(HaveOccurred())
+
+			_, ok := registry.FindBatch("batch-1")
+			Ω(ok).Should(BeFalse())
+			again, created, _ := registry.Create("batch-1", 3)
+			Ω(created).Should(BeTrue())
+			Ω(again.id).ShouldNot(Equal(job.id))
+
+			Ω(registry.Delete(job.id)).Should(BeFalse())
+		})
+	})
+
+	Describe("ResultsSince", func() {
+		var job *startAuctionJob
+
+		BeforeEach(func() {
+			job, _, _ = registry.Create("batch-1", 3)
+			for i := 0; i < 3; i++ {
+				job.AddResult(auctiontypes.StartAuctionResult{Winner: fmt.Sprintf("rep-lite-%d", i)})
+			}
+		})
+
+		winnersSince := func(offset int) []string {
+			results, done := job.ResultsSince(offset)
+			Ω(done).Should(BeFalse())
+
+			winners := []string{}
+			for _, result := range results {
+				winners = append(winners, result.Winner)
+			}
+			return winners
+		}
+
+		It("returns the results from the offset on", func() {
+			Ω(winnersSince(0)).Should(Equal([]string{"rep-lite-0", "rep-lite-1", "rep-lite-2"}))
+			Ω(winnersSince(1)).Should(Equal([]string{"rep-lite-1", "rep-lite-2"}))
+			Ω(winnersSince(10)).Should(BeEmpty())
+		})
+
+		It("reports whether the job is done", func() {
+			job.Finish()
+			results, done := job.ResultsSince(3)
+			Ω(results).Should(BeEmpty())
+			Ω(done).Should(BeTrue())
+		})
+
+		It("doesn't share its results with the caller", func() {
+			results, _ := job.ResultsSince(0)
+			results[0].Winner = "someone else"
+
+			results, _ = job.ResultsSince(0)
+			Ω(results[0].Winner).Should(Equal("rep-lite-0"))
+		})
+	})
+
+	It("signals changes by closing the Changed channel", func() {
+		job, _, _ := registry.Create("", 2)
+
+		changed := job.Changed()
+		job.AddResult(auctiontypes.StartAuctionResult{})
+		_, open := <-changed
+		Ω(open).Should(BeFalse())
+
+		changed = job.Changed()
+		job.Finish()
+		_, open = <-changed
+		Ω(open).Should(BeFalse())
+	})
+
+	It("summarizes the job", func() {
+		job, _, _ := registry.Create("batch-1", 3)
+		job.AddResult(auctiontypes.StartAuctionResult{})
+		job.Cancel()
+
+		summary := job.Summary()
+		Ω(summary.JobID).Should(Equal(job.id))
+		Ω(summary.BatchID).Should(Equal("batch-1"))
+		Ω(summary.NumAuctions).Should(Equal(3))
+		Ω(summary.NumResults).Should(Equal(1))
+		Ω(summary.Done).Should(BeFalse())
+		Ω(summary.Cancelled).Should(BeTrue())
+		Ω(job.Context().Err()).Should(HaveOccurred())
+	})
+
+	Describe("forgetting finished jobs", func() {
+		It("forgets finished jobs after the ttl", func() {
+			registry = newJobRegistry(10*time.Millisecond, 0)
+			finished, _, _ := registry.Create("batch-1", 1)
+			running, _, _ := registry.Create("batch-2", 1)
+			finished.Finish()
+
+			Ω(jobIDs()).Should(HaveLen(2))
+			time.Sleep(20 * time.Millisecond)
+			Ω(jobIDs()).Should(Equal([]string{running.id}))
+
+			_, ok := registry.Get(finished.id)
+			Ω(ok).Should(BeFalse())
+			_, ok = registry.FindBatch("batch-1")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("keeps only the latest maxFinished finished jobs", func() {
+			registry = newJobRegistry(0, 2)
+			jobs := []*startAuctionJob{}
+			for i := 0; i < 4; i++ {
+				job, _, _ := registry.Create(fmt.Sprintf("batch-%d", i), 1)
+				jobs = append(jobs, job)
+			}
+			running, _, _ := registry.Create("batch-running", 1)
+			for _, job := range jobs {
+				job.Finish()
+				time.Sleep(time.Millisecond)
+			}
+
+			Ω(jobIDs()).Should(Equal([]string{jobs[2].id, jobs[3].id, running.id}))
+		})
+
+		It("refuses to run the batch of a forgotten job again", func() {
+			registry = newJobRegistry(0, 1)
+			forgotten, _, _ := registry.Create("batch-1", 1)
+			forgotten.Finish()
+			time.Sleep(time.Millisecond)
+			kept, _, _ := registry.Create("batch-2", 1)
+			kept.Finish()
+
+			_, created, err := registry.Create("batch-1", 1)
+			Ω(err).Should(Equal(errBatchForgotten))
+			Ω(created).Should(BeFalse())
+			Ω(jobIDs()).Should(Equal([]string{kept.id}))
+		})
+	})
+})
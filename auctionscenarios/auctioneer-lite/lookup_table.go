@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// repLookupTable maps rep guids to the addresses the reps are actually
+// listening on.  It is refreshed in the background and, rate limited, whenever
+// a lookup misses, so that reps that move to new host ports are picked up.
+type repLookupTable struct {
+	discovery          repDiscovery
+	minRefreshInterval time.Duration
+
+	refreshLock *sync.Mutex
+	lastAttempt time.Time
+
+	lock            *sync.RWMutex
+	table           map[string]string
+	fetchedAt       time.Time
+	misses          int
+	unresolved      int
+	refreshes       int
+	failedRefreshes int
+}
+
+type lookupStats struct {
+	NumReps         int
+	FetchedAt       time.Time
+	Age             string
+	AgeSeconds      float64
+	Misses          int
+	Unresolved      int
+	Refreshes       int
+	FailedRefreshes int
+}
+
+func newRepLookupTable(discovery repDiscovery, minRefreshInterval time.Duration) *repLookupTable {
+	return &repLookupTable{
+		discovery:          discovery,
+		minRefreshInterval: minRefreshInterval,
+		refreshLock:        &sync.Mutex{},
+		lock:               &sync.RWMutex{},
+	}
+}
+
+func (t *repLookupTable) Refresh() error {
+	t.refreshLock.Lock()
+	defer t.refreshLock.Unlock()
+	return t.refresh()
+}
+
+func (t *repLookupTable) refresh() error {
+	t.lastAttempt = time.Now()
+	table, err := t.discovery.Discover()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if err != nil {
+		t.failedRefreshes++
+		return err
+	}
+	t.table = table
+	t.fetchedAt = time.Now()
+	t.refreshes++
+	return nil
+}
+
+// refreshIfStale refreshes the table unless it was (or is being) refreshed
+// within the last minRefreshInterval, so that a burst of misses costs a single
+// fetch.
+func (t *repLookupTable) refreshIfStale() {
+	t.refreshLock.Lock()
+	defer t.refreshLock.Unlock()
+
+	if time.Since(t.lastAttempt) < t.minRefreshInterval {
+		return
+	}
+	err := t.refresh()
+	if err != nil {
+		fmt.Println("failed to refresh rep lookup table", err.Error())
+	}
+}
+
+// RefreshEvery refreshes the table every interval, forever.
+func (t *repLookupTable) RefreshEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		err := t.Refresh()
+		if err != nil {
+			fmt.Println("failed to refresh rep lookup table", err.Error())
+		}
+	}
+}
+
+func (t *repLookupTable) Lookup(repGuid string) (string, error) {
+	address, ok := t.get(repGuid)
+	if ok {
+		return address, nil
+	}
+
+	t.lock.Lock()
+	t.misses++
+	t.lock.Unlock()
+
+	t.refreshIfStale()
+
+	address, ok = t.get(repGuid)
+	if ok {
+		return address, nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.unresolved++
+	if t.table == nil {
+		return "", errors.New("lookupTable uninitialized")
+	}
+	return "", errors.New("unkown rep-guid: " + repGuid)
+}
+
+func (t *repLookupTable) get(repGuid string) (string, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	address, ok := t.table[repGuid]
+	return address, ok
+}
+
+func (t *repLookupTable) Table() map[string]string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.table
+}
+
+func (t *repLookupTable) Stats() lookupStats {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	age := time.Since(t.fetchedAt)
+	return lookupStats{
+		NumReps:         len(t.table),
+		FetchedAt:       t.fetchedAt,
+		Age:             age.String(),
+		AgeSeconds:      age.Seconds(),
+		Misses:          t.misses,
+		Unresolved:      t.unresolved,
+		Refreshes:       t.refreshes,
+		FailedRefreshes: t.failedRefreshes,
+	}
+}
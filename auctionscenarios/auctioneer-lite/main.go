@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -17,69 +18,39 @@ import (
 
 	"github.com/cloudfoundry-incubator/auction/communication/nats/auction_nats_client"
 
-	"github.com/cloudfoundry/gunk/timeprovider"
 	"github.com/cloudfoundry/gunk/workpool"
 	"github.com/cloudfoundry/yagnats"
 
-	"github.com/cloudfoundry/storeadapter/etcdstoreadapter"
-
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs"
-
 	"github.com/cloudfoundry-incubator/auction/communication/http/auction_http_client"
 
 	"github.com/cloudfoundry-incubator/auction/auctionrunner"
 	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
 )
 
 var timeout = flag.Duration("timeout", time.Second, "timeout for nats responses")
-var etcdCluster = flag.String("etcdCluster", "", "etcd cluster")
+var etcdCluster = flag.String("etcdCluster", "", "etcd cluster, for the bbs rep discovery")
+var repDiscoveryName = flag.String("repDiscovery", bbsDiscovery, "how to find the reps, one of bbs (rep-lite LRPs in etcd), file (-repAddressFile), list (-repAddresses) or srv (-repSRV)")
+var repAddressFile = flag.String("repAddressFile", "", "an address table, such as the one the launcher writes, for the file rep discovery")
+var repAddressList = flag.String("repAddresses", "", "comma-separated guid=address pairs for the list rep discovery")
+var repSRVName = flag.String("repSRV", "", "a DNS SRV name with one target per rep-lite process for the srv rep discovery")
+var repsPerProcess = flag.Int("repsPerProcess", 1, "how many reps each rep-lite process hosts (see rep-lite's -numReps), for the bbs and srv rep discoveries")
 var natsUsername = flag.String("natsUsername", "", "nats username")
 var natsPassword = flag.String("natsPassword", "", "nats password")
 var natsAddresses = flag.String("natsAddresses", "", "nats addresses")
+var listenAddress = flag.String("listenAddress", "0.0.0.0:8080", "the address to serve auction requests on")
+var lookupRefreshInterval = flag.Duration("lookupRefreshInterval", 30*time.Second, "how often to refresh the rep lookup table in the background (0 disables)")
+var lookupMissRefreshInterval = flag.Duration("lookupMissRefreshInterval", time.Second, "the minimum time between refreshes of the rep lookup table triggered by unknown rep guids")
+var jobTTL = flag.Duration("jobTTL", 10*time.Minute, "how long to keep finished start auction jobs that were never deleted (0 keeps them until deleted)")
+var maxFinishedJobs = flag.Int("maxFinishedJobs", 100, "how many finished start auction jobs to keep at most, oldest dropped first (0 for no limit)")
+var auctioneerGuid = flag.String("auctioneerGuid", "", "auctioneer-guid, used to subscribe to auction requests over nats")
 
-var lookupTable map[string]string
-var lookupTableLock *sync.RWMutex
-
-func FetchLookupTable() {
-	store := etcdstoreadapter.NewETCDStoreAdapter(strings.Split(*etcdCluster, ","), workpool.NewWorkPool(10))
-	store.Connect()
-	BBS := bbs.NewBBS(store, timeprovider.NewTimeProvider(), lager.NewLogger("auctioneer-bbs"))
-
-	actuals, err := BBS.GetAllActualLRPs()
-	if err != nil {
-		log.Fatalln("failed to fetch reps from etcd", err)
-	}
-
-	lookupTableLock.Lock()
-	lookupTable = map[string]string{}
-	for _, actual := range actuals {
-		if strings.HasPrefix(actual.ProcessGuid, "rep-lite") && len(actual.Ports) == 1 {
-			lookupTable[actual.ProcessGuid] = fmt.Sprintf("http://%s:%d", actual.Host, actual.Ports[0].HostPort)
-		}
-	}
-	lookupTableLock.Unlock()
-}
-
-func AddressLookup(repGuid string) (string, error) {
-	lookupTableLock.RLock()
-	defer lookupTableLock.RUnlock()
-
-	if lookupTable == nil {
-		return "", errors.New("lookupTable uninitialized")
-	}
-
-	address, ok := lookupTable[repGuid]
-	if !ok {
-		return "", errors.New("unkown rep-guid: " + repGuid)
-	}
-
-	return address, nil
-}
+var lookupTable *repLookupTable
 
 func transformRepAddresses(repAddresses []auctiontypes.RepAddress) []auctiontypes.RepAddress {
 	transformed := []auctiontypes.RepAddress{}
 	for _, repAddress := range repAddresses {
-		address, err := AddressLookup(repAddress.RepGuid)
+		address, err := lookupTable.Lookup(repAddress.RepGuid)
 		if err != nil {
 			fmt.Println(err.Error())
 			continue
@@ -95,45 +66,50 @@ func transformRepAddresses(repAddresses []auctiontypes.RepAddress) []auctiontype
 
 func main() {
 	flag.Parse()
-	lookupTableLock = &sync.RWMutex{}
 
-	if *etcdCluster == "" {
-		log.Fatalln("you must provide an etcd cluster")
+	discovery, err := newRepDiscovery(*repDiscoveryName)
+	if err != nil {
+		log.Fatalln(err)
 	}
+	lookupTable = newRepLookupTable(discovery, *lookupMissRefreshInterval)
 
-	repNATSClient := connectToNATS()
+	natsClient, repNATSClient := connectToNATS()
 
-	FetchLookupTable()
+	err = lookupTable.Refresh()
+	if err != nil {
+		log.Fatalln("failed to discover reps", err)
+	}
+	if *lookupRefreshInterval > 0 {
+		go lookupTable.RefreshEvery(*lookupRefreshInterval)
+	}
 
 	var repHTTPClient auctiontypes.RepPoolClient
 	repHTTPClient = auction_http_client.New(&http.Client{
 		Timeout: *timeout,
 	}, lager.NewLogger("auctioneer-http"))
 
+	getRepClient := func(mode string) (auctiontypes.RepPoolClient, bool) {
+		if mode == "NATS" {
+			return repNATSClient, false
+		}
+		return repHTTPClient, true
+	}
+
 	getCommunicationMode := func(r *http.Request) (auctiontypes.RepPoolClient, bool) {
-		var httpMode bool
-		var repClient auctiontypes.RepPoolClient
-		if r.URL.Query().Get("mode") == "NATS" {
-			repClient = repNATSClient
-			httpMode = false
-		} else {
-			repClient = repHTTPClient
-			httpMode = true
+		return getRepClient(r.URL.Query().Get("mode"))
+	}
+
+	if natsClient != nil && *auctioneerGuid != "" {
+		err := serveAuctionsOverNATS(natsClient, *auctioneerGuid, getRepClient)
+		if err != nil {
+			log.Fatalln("failed to subscribe to auctions over nats:", err)
 		}
-		return repClient, httpMode
 	}
 
-	lock := &sync.Mutex{}
-	results := []auctiontypes.StartAuctionResult{}
-	done := false
+	jobs := newJobRegistry(*jobTTL, *maxFinishedJobs)
 
 	http.HandleFunc("/start-auctions", func(w http.ResponseWriter, r *http.Request) {
-		lock.Lock()
-		results = []auctiontypes.StartAuctionResult{}
-		done = false
-		lock.Unlock()
-
-		var auctionRequests []auctiontypes.StartAuctionRequest
+		var auctionRequests []auctiondistributor.ScheduledStartAuctionRequest
 		err := json.NewDecoder(r.Body).Decode(&auctionRequests)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -147,52 +123,129 @@ func main() {
 
 		repClient, httpMode := getCommunicationMode(r)
 
-		t := time.Now()
-		go func() {
-			workers := workpool.NewWorkPool(maxConcurrent)
-
-			wg := &sync.WaitGroup{}
-			wg.Add(len(auctionRequests))
-			for _, auctionRequest := range auctionRequests {
-				auctionRequest := auctionRequest
-				workers.Submit(func() {
-					if httpMode {
-						auctionRequest.RepAddresses = transformRepAddresses(auctionRequest.RepAddresses)
-					}
-					auctionResult, _ := auctionrunner.New(repClient).RunLRPStartAuction(auctionRequest)
-					auctionResult.Duration = time.Since(t)
-					lock.Lock()
-					results = append(results, auctionResult)
-					lock.Unlock()
-					wg.Done()
-				})
-			}
+		job, created, err := jobs.Create(r.URL.Query().Get("batch"), len(auctionRequests))
+		if err == errBatchForgotten {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if created {
+			go func() {
+				runStartAuctions(job.Context(), auctionRequests, repClient, httpMode, maxConcurrent, job.AddResult)
+				job.Finish()
+			}()
+		}
 
-			wg.Wait()
-			lock.Lock()
-			done = true
-			lock.Unlock()
-			workers.Stop()
-		}()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job.Summary())
 	})
 
 	http.HandleFunc("/start-auctions-results", func(w http.ResponseWriter, r *http.Request) {
-		var statusCode int
-		lock.Lock()
+		job, ok := jobs.Get(r.URL.Query().Get("job"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		offset, err := parseOffset(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		results, done := job.ResultsSince(offset)
+		payload, _ := json.Marshal(results)
 		if done {
-			statusCode = http.StatusCreated
+			w.WriteHeader(http.StatusCreated)
 		} else {
-			statusCode = http.StatusOK
+			w.WriteHeader(http.StatusOK)
 		}
-		payload, _ := json.Marshal(results)
-		results = []auctiontypes.StartAuctionResult{}
-		lock.Unlock()
-		w.WriteHeader(statusCode)
 		w.Write(payload)
 	})
 
+	http.HandleFunc("/start-auctions-stream", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobs.Get(r.URL.Query().Get("job"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		offset, err := parseOffset(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+		for {
+			changed := job.Changed()
+			results, done := job.ResultsSince(offset)
+			for _, result := range results {
+				err := encoder.Encode(result)
+				if err != nil {
+					return
+				}
+			}
+			offset += len(results)
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if done {
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	http.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			batchID := r.URL.Query().Get("batch")
+			if batchID == "" {
+				json.NewEncoder(w).Encode(jobs.List())
+				return
+			}
+			summaries := []auctiondistributor.StartAuctionsJob{}
+			if job, ok := jobs.FindBatch(batchID); ok {
+				summaries = append(summaries, job.Summary())
+			}
+			json.NewEncoder(w).Encode(summaries)
+		case "DELETE":
+			if !jobs.Delete(r.URL.Query().Get("job")) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/jobs/cancel", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		job, ok := jobs.Get(r.URL.Query().Get("job"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		job.Cancel()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
 	http.HandleFunc("/stop-auctions", func(w http.ResponseWriter, r *http.Request) {
-		var auctionRequests []auctiontypes.StopAuctionRequest
+		var auctionRequests []auctiondistributor.ScheduledStopAuctionRequest
 		err := json.NewDecoder(r.Body).Decode(&auctionRequests)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -206,45 +259,99 @@ func main() {
 		}
 
 		repClient, httpMode := getCommunicationMode(r)
-		workers := workpool.NewWorkPool(maxConcurrent)
 
 		lock := &sync.Mutex{}
-		wg := &sync.WaitGroup{}
-		wg.Add(len(auctionRequests))
 		w.WriteHeader(http.StatusOK)
 		encoder := json.NewEncoder(w)
-		for _, auctionRequest := range auctionRequests {
+		runStopAuctions(r.Context(), auctionRequests, repClient, httpMode, maxConcurrent, func(auctionResult auctiontypes.StopAuctionResult) {
+			lock.Lock()
+			encoder.Encode(auctionResult)
+			lock.Unlock()
+		})
+	})
 
-			auctionRequest := auctionRequest
-			workers.Submit(func() {
-				if httpMode {
-					auctionRequest.RepAddresses = transformRepAddresses(auctionRequest.RepAddresses)
-				}
-				auctionResult, _ := auctionrunner.New(repClient).RunLRPStopAuction(auctionRequest)
-				lock.Lock()
-				encoder.Encode(auctionResult)
-				lock.Unlock()
-				wg.Done()
-			})
+	http.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
+		err := lookupTable.Refresh()
+		if err != nil {
+			fmt.Println("failed to discover reps", err.Error())
 		}
-
-		wg.Wait()
-		workers.Stop()
+		json.NewEncoder(w).Encode(lookupTable.Table())
 	})
 
-	http.HandleFunc("/routes", func(w http.ResponseWriter, r *http.Request) {
-		FetchLookupTable()
-		lookupTableLock.RLock()
-		defer lookupTableLock.RUnlock()
-		json.NewEncoder(w).Encode(lookupTable)
+	http.HandleFunc("/lookup-stats", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(lookupTable.Stats())
 	})
 
 	fmt.Println("auctioneering")
 
-	panic(http.ListenAndServe("0.0.0.0:8080", nil))
+	panic(http.ListenAndServe(*listenAddress, nil))
+}
+
+func parseOffset(r *http.Request) (int, error) {
+	if r.URL.Query().Get("offset") == "" {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	return offset, nil
+}
+
+func runStartAuctions(ctx context.Context, auctionRequests []auctiondistributor.ScheduledStartAuctionRequest, repClient auctiontypes.RepPoolClient, httpMode bool, maxConcurrent int, onResult func(auctiontypes.StartAuctionResult)) {
+	t := time.Now()
+	workers := workpool.NewWorkPool(maxConcurrent)
+
+	wg := &sync.WaitGroup{}
+	auctiondistributor.ReleaseOnArrival(ctx, t, auctionRequests, func(auctionRequest auctiondistributor.ScheduledStartAuctionRequest) {
+		wg.Add(1)
+		workers.Submit(func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			if httpMode {
+				auctionRequest.RepAddresses = transformRepAddresses(auctionRequest.RepAddresses)
+			}
+			auctionResult, _ := auctionrunner.New(repClient).RunLRPStartAuction(auctionRequest.StartAuctionRequest)
+			auctionResult.Duration = time.Since(t.Add(auctionRequest.ArrivalOffset))
+			onResult(auctionResult)
+		})
+	})
+
+	wg.Wait()
+	workers.Stop()
+}
+
+func runStopAuctions(ctx context.Context, auctionRequests []auctiondistributor.ScheduledStopAuctionRequest, repClient auctiontypes.RepPoolClient, httpMode bool, maxConcurrent int, onResult func(auctiontypes.StopAuctionResult)) {
+	workers := workpool.NewWorkPool(maxConcurrent)
+
+	wg := &sync.WaitGroup{}
+	auctiondistributor.ReleaseStopAuctionsOnArrival(ctx, time.Now(), auctionRequests, func(auctionRequest auctiondistributor.ScheduledStopAuctionRequest) {
+		wg.Add(1)
+		workers.Submit(func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			if httpMode {
+				auctionRequest.RepAddresses = transformRepAddresses(auctionRequest.RepAddresses)
+			}
+			auctionResult, _ := auctionrunner.New(repClient).RunLRPStopAuction(auctionRequest.StopAuctionRequest)
+			onResult(auctionResult)
+		})
+	})
+
+	wg.Wait()
+	workers.Stop()
 }
 
-func connectToNATS() auctiontypes.RepPoolClient {
+func connectToNATS() (yagnats.NATSClient, auctiontypes.RepPoolClient) {
 	if *natsAddresses != "" && *natsUsername != "" && *natsPassword != "" {
 		natsMembers := []string{}
 		for _, addr := range strings.Split(*natsAddresses, ",") {
@@ -266,8 +373,8 @@ func connectToNATS() auctiontypes.RepPoolClient {
 			log.Fatalln("no rep client:", err)
 		}
 
-		return repClient
+		return client, repClient
 	}
 
-	return nil
+	return nil, nil
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry/yagnats"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+// The NATS server runs the auctions it is handed with these; the specs swap
+// them out to do without reps.
+var natsStartAuctionsRunner = runStartAuctions
+var natsStopAuctionsRunner = runStopAuctions
+
+func serveAuctionsOverNATS(natsClient yagnats.NATSClient, auctioneerGuid string, getRepClient func(mode string) (auctiontypes.RepPoolClient, bool)) error {
+	runs := newNATSRuns()
+
+	_, err := natsClient.Subscribe(auctiondistributor.StartAuctionsSubject(auctioneerGuid), func(msg *yagnats.Message) {
+		var message auctiondistributor.StartAuctionsMessage
+		err := json.Unmarshal(msg.Payload, &message)
+		if err != nil {
+			fmt.Println("failed to decode start auctions:", err.Error())
+			return
+		}
+
+		repClient, httpMode := getRepClient(message.Mode)
+		ctx, done := runs.Start(msg.ReplyTo)
+		go func() {
+			defer done()
+			natsStartAuctionsRunner(ctx, message.Requests(), repClient, httpMode, message.MaxConcurrent, func(auctionResult auctiontypes.StartAuctionResult) {
+				payload, _ := json.Marshal(auctionResult)
+				natsClient.Publish(msg.ReplyTo, payload)
+			})
+		}()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = natsClient.Subscribe(auctiondistributor.StopAuctionsSubject(auctioneerGuid), func(msg *yagnats.Message) {
+		var message auctiondistributor.StopAuctionsMessage
+		err := json.Unmarshal(msg.Payload, &message)
+		if err != nil {
+			fmt.Println("failed to decode stop auctions:", err.Error())
+			return
+		}
+
+		repClient, httpMode := getRepClient(message.Mode)
+		ctx, done := runs.Start(msg.ReplyTo)
+		go func() {
+			defer done()
+			natsStopAuctionsRunner(ctx, message.Requests(), repClient, httpMode, message.MaxConcurrent, func(auctionResult auctiontypes.StopAuctionResult) {
+				payload, _ := json.Marshal(auctionResult)
+				natsClient.Publish(msg.ReplyTo, payload)
+			})
+		}()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = natsClient.Subscribe(auctiondistributor.CancelAuctionsSubject(auctioneerGuid), func(msg *yagnats.Message) {
+		var message auctiondistributor.CancelAuctionsMessage
+		err := json.Unmarshal(msg.Payload, &message)
+		if err != nil {
+			fmt.Println("failed to decode cancel auctions:", err.Error())
+			return
+		}
+
+		runs.Cancel(message.ReplyTo)
+	})
+
+	return err
+}
+
+// natsRuns tracks the runs in flight by reply subject, which is what a
+// CancelAuctionsMessage names.
+type natsRuns struct {
+	lock    *sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newNATSRuns() *natsRuns {
+	return &natsRuns{
+		lock:    &sync.Mutex{},
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// Start returns the run's context and a func to call once the run is over.
+func (r *natsRuns) Start(replyTo string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.lock.Lock()
+	r.cancels[replyTo] = cancel
+	r.lock.Unlock()
+
+	return ctx, func() {
+		r.lock.Lock()
+		delete(r.cancels, replyTo)
+		r.lock.Unlock()
+		cancel()
+	}
+}
+
+func (r *natsRuns) Cancel(replyTo string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cancel, ok := r.cancels[replyTo]
+	if ok {
+		cancel()
+		delete(r.cancels, replyTo)
+	}
+	return ok
+}
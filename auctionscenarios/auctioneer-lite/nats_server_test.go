@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry/yagnats"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+var _ = Describe("serving auctions over NATS", func() {
+	var natsClient *fakeNATSClient
+	var distributor auctiondistributor.AuctionDistributor
+	var startAuctions []models.LRPStartAuction
+	var stopAuctions []models.LRPStopAuction
+
+	noReps := func(mode string) (auctiontypes.RepPoolClient, bool) {
+		return nil, false
+	}
+
+	serve := func(auctioneerGuids ...string) {
+		for _, auctioneerGuid := range auctioneerGuids {
+			err := serveAuctionsOverNATS(natsClient, auctioneerGuid, noReps)
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+	}
+
+	instanceGuids := func(results []auctiontypes.StartAuctionResult) []string {
+		guids := []string{}
+		for _, result := range results {
+			guids = append(guids, result.LRPStartAuction.InstanceGuid)
+		}
+		sort.Strings(guids)
+		return guids
+	}
+
+	lostInstanceGuids := func(outcome auctiondistributor.RunOutcome) []string {
+		guids := []string{}
+		for _, startAuction := range outcome.LostStartAuctions {
+			guids = append(guids, startAuction.InstanceGuid)
+		}
+		sort.Strings(guids)
+		return guids
+	}
+
+	BeforeEach(func() {
+		natsClient = newFakeNATSClient()
+
+		partitionStrategy, err := auctiondistributor.NewPartitionStrategy(auctiondistributor.RoundRobinPartitioning, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		arrivalSchedule, err := auctiondistributor.NewArrivalSchedule(auctiondistributor.BurstArrivals, 0, "")
+		Ω(err).ShouldNot(HaveOccurred())
+		distributor = auctiondistributor.NewNATSAuctionDistributor(natsClient, []string{"auctioneer-1", "auctioneer-2"}, 10, "nats", partitionStrategy, arrivalSchedule)
+
+		startAuctions = []models.LRPStartAuction{}
+		for _, guid := range []string{"instance-0", "instance-1", "instance-2", "instance-3"} {
+			startAuction := models.LRPStartAuction{InstanceGuid: guid}
+			startAuction.DesiredLRP.ProcessGuid = "process-" + guid
+			startAuctions = append(startAuctions, startAuction)
+		}
+		stopAuctions = []models.LRPStopAuction{
+			{ProcessGuid: "process-a", Index: 0},
+			{ProcessGuid: "process-b", Index: 0},
+		}
+
+		natsStartAuctionsRunner = func(ctx context.Context, requests []auctiondistributor.ScheduledStartAuctionRequest, repClient auctiontypes.RepPoolClient, httpMode bool, maxConcurrent int, onResult func(auctiontypes.StartAuctionResult)) {
+			for _, request := range requests {
+				onResult(auctiontypes.StartAuctionResult{LRPStartAuction: request.LRPStartAuction, Winner: "rep-lite-1"})
+			}
+		}
+		natsStopAuctionsRunner = func(ctx context.Context, requests []auctiondistributor.ScheduledStopAuctionRequest, repClient auctiontypes.RepPoolClient, httpMode bool, maxConcurrent int, onResult func(auctiontypes.StopAuctionResult)) {
+			for _, request := range requests {
+				onResult(auctiontypes.StopAuctionResult{LRPStopAuction: request.LRPStopAuction, Winner: "rep-lite-1"})
+			}
+		}
+	})
+
+	AfterEach(func() {
+		natsStartAuctionsRunner = runStartAuctions
+		natsStopAuctionsRunner = runStopAuctions
+	})
+
+	It("gathers every start auction's result from the reply subject", func() {
+		serve("auctioneer-1", "auctioneer-2")
+
+		results, outcome := distributor.HoldStartAuctionsWithContext(context.Background(), 2, startAuctions, nil, auctiontypes.StartAuctionRules{})
+		Ω(instanceGuids(results)).Should(Equal([]string{"instance-0", "instance-1", "instance-2", "instance-3"}))
+		Ω(outcome.Complete).Should(BeTrue())
+		Ω(outcome.Auctioneers).Should(BeEmpty())
+
+		Ω(natsClient.SubscriptionCount()).Should(Equal(6), "the reply subscription should be gone")
+	})
+
+	It("gathers every stop auction's result from the reply subject", func() {
+		serve("auctioneer-1", "auctioneer-2")
+
+		results, outcome := distributor.HoldStopAuctionsWithContext(context.Background(), 2, stopAuctions, nil)
+		Ω(results).Should(HaveLen(2))
+		Ω(outcome.Complete).Should(BeTrue())
+		Ω(outcome.LostStopAuctions).Should(BeEmpty())
+	})
+
+	It("returns what arrived when the run times out, and accounts for the rest", func() {
+		serve("auctioneer-1")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		results, outcome := distributor.HoldStartAuctionsWithContext(ctx, 2, startAuctions, nil, auctiontypes.StartAuctionRules{})
+
+		Ω(instanceGuids(results)).Should(Equal([]string{"instance-0", "instance-2"}))
+		Ω(outcome.Complete).Should(BeFalse())
+		Ω(lostInstanceGuids(outcome)).Should(Equal([]string{"instance-1", "instance-3"}))
+		Ω(outcome.Auctioneers).Should(HaveLen(1))
+		Ω(outcome.Auctioneers[0].Auctioneer).Should(Equal("auctioneer-2"))
+		Ω(outcome.Auctioneers[0].NUnacknowledged).Should(Equal(2))
+	})
+
+	It("cancels the auctioneers' runs when the run times out", func() {
+		serve("auctioneer-1", "auctioneer-2")
+
+		cancelled := make(chan struct{}, 2)
+		natsStartAuctionsRunner = func(ctx context.Context, requests []auctiondistributor.ScheduledStartAuctionRequest, repClient auctiontypes.RepPoolClient, httpMode bool, maxConcurrent int, onResult func(auctiontypes.StartAuctionResult)) {
+			<-ctx.Done()
+			cancelled <- struct{}{}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		results, outcome := distributor.HoldStartAuctionsWithContext(ctx, 2, startAuctions, nil, auctiontypes.StartAuctionRules{})
+		Ω(results).Should(BeEmpty())
+		Ω(outcome.Complete).Should(BeFalse())
+
+		Ω(natsClient.PublishedSubjects()).Should(ContainElement("auctioneer-1.cancel-auctions"))
+		Ω(natsClient.PublishedSubjects()).Should(ContainElement("auctioneer-2.cancel-auctions"))
+		for i := 0; i < 2; i++ {
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				Fail("an auctioneer kept running a cancelled run")
+			}
+		}
+	})
+
+	It("reports a partial outcome without waiting out the run when a publish fails", func() {
+		serve("auctioneer-1", "auctioneer-2")
+		natsClient.FailPublishingTo(auctiondistributor.StartAuctionsSubject("auctioneer-2"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		results, outcome := distributor.HoldStartAuctionsWithContext(ctx, 2, startAuctions, nil, auctiontypes.StartAuctionRules{})
+		Ω(ctx.Err()).ShouldNot(HaveOccurred())
+
+		Ω(instanceGuids(results)).Should(Equal([]string{"instance-0", "instance-2"}))
+		Ω(outcome.Complete).Should(BeFalse())
+		Ω(lostInstanceGuids(outcome)).Should(Equal([]string{"instance-1", "instance-3"}))
+		Ω(outcome.Auctioneers).Should(HaveLen(1))
+		Ω(outcome.Auctioneers[0].Auctioneer).Should(Equal("auctioneer-2"))
+		Ω(outcome.Auctioneers[0].Failures).Should(HaveLen(1))
+		Ω(outcome.Auctioneers[0].Failures[0].Class).Should(Equal(auctiondistributor.PublishFailure))
+		Ω(natsClient.PublishedSubjects()).ShouldNot(ContainElement("auctioneer-1.cancel-auctions"))
+	})
+})
+
+// fakeNATSClient delivers messages in process, to exact subjects only.
+type fakeNATSClient struct {
+	yagnats.NATSClient
+
+	lock            *sync.Mutex
+	nextSid         int64
+	subscriptions   map[int64]fakeSubscription
+	failingSubjects map[string]bool
+	published       []string
+}
+
+type fakeSubscription struct {
+	subject  string
+	callback yagnats.Callback
+}
+
+func newFakeNATSClient() *fakeNATSClient {
+	return &fakeNATSClient{
+		lock:            &sync.Mutex{},
+		subscriptions:   map[int64]fakeSubscription{},
+		failingSubjects: map[string]bool{},
+	}
+}
+
+func (c *fakeNATSClient) Subscribe(subject string, callback yagnats.Callback) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.nextSid++
+	c.subscriptions[c.nextSid] = fakeSubscription{subject: subject, callback: callback}
+	return c.nextSid, nil
+}
+
+func (c *fakeNATSClient) Unsubscribe(sid int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.subscriptions, sid)
+	return nil
+}
+
+func (c *fakeNATSClient) Publish(subject string, payload []byte) error {
+	return c.PublishWithReplyTo(subject, "", payload)
+}
+
+func (c *fakeNATSClient) PublishWithReplyTo(subject, reply string, payload []byte) error {
+	c.lock.Lock()
+	if c.failingSubjects[subject] {
+		c.lock.Unlock()
+		return errors.New("publish failed")
+	}
+	c.published = append(c.published, subject)
+	callbacks := []yagnats.Callback{}
+	for _, subscription := range c.subscriptions {
+		if subscription.subject == subject {
+			callbacks = append(callbacks, subscription.callback)
+		}
+	}
+	c.lock.Unlock()
+
+	for _, callback := range callbacks {
+		callback(&yagnats.Message{Subject: subject, ReplyTo: reply, Payload: payload})
+	}
+	return nil
+}
+
+func (c *fakeNATSClient) FailPublishingTo(subject string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.failingSubjects[subject] = true
+}
+
+func (c *fakeNATSClient) SubscriptionCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.subscriptions)
+}
+
+func (c *fakeNATSClient) PublishedSubjects() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]string{}, c.published...)
+}
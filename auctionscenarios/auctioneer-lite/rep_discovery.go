@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"github.com/cloudfoundry/gunk/timeprovider"
+	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/cloudfoundry/storeadapter/etcdstoreadapter"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/addresstable"
+)
+
+const (
+	bbsDiscovery  = "bbs"
+	fileDiscovery = "file"
+	listDiscovery = "list"
+	srvDiscovery  = "srv"
+)
+
+// A repDiscovery finds the address of every rep-lite rep, keyed by rep guid.
+type repDiscovery interface {
+	Discover() (map[string]string, error)
+}
+
+func newRepDiscovery(name string) (repDiscovery, error) {
+	switch name {
+	case bbsDiscovery:
+		if *etcdCluster == "" {
+			return nil, errors.New("the bbs rep discovery needs -etcdCluster")
+		}
+		return &bbsRepDiscovery{
+			etcdCluster:    strings.Split(*etcdCluster, ","),
+			repsPerProcess: *repsPerProcess,
+		}, nil
+	case fileDiscovery:
+		if *repAddressFile == "" {
+			return nil, errors.New("the file rep discovery needs -repAddressFile")
+		}
+		return &fileRepDiscovery{path: *repAddressFile}, nil
+	case listDiscovery:
+		if *repAddressList == "" {
+			return nil, errors.New("the list rep discovery needs -repAddresses")
+		}
+		return &listRepDiscovery{list: *repAddressList}, nil
+	case srvDiscovery:
+		if *repSRVName == "" {
+			return nil, errors.New("the srv rep discovery needs -repSRV")
+		}
+		return &srvRepDiscovery{
+			name:           *repSRVName,
+			repsPerProcess: *repsPerProcess,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown rep discovery: %s", name)
+	}
+}
+
+// addProcess registers the reps hosted by one rep-lite process: the process
+// itself, or rep-lite's -numReps reps served under their guids.
+func addProcess(table map[string]string, processGuid string, address string, repsPerProcess int) {
+	if repsPerProcess <= 1 {
+		table[processGuid] = address
+		return
+	}
+	for i := 1; i <= repsPerProcess; i++ {
+		guid := fmt.Sprintf("%s-%d", processGuid, i)
+		table[guid] = address + "/" + guid
+	}
+}
+
+// bbsRepDiscovery finds the rep-lite LRPs running on Diego.
+type bbsRepDiscovery struct {
+	etcdCluster    []string
+	repsPerProcess int
+	bbs            *bbs.BBS
+}
+
+func (d *bbsRepDiscovery) Discover() (map[string]string, error) {
+	if d.bbs == nil {
+		store := etcdstoreadapter.NewETCDStoreAdapter(d.etcdCluster, workpool.NewWorkPool(10))
+		err := store.Connect()
+		if err != nil {
+			return nil, err
+		}
+		d.bbs = bbs.NewBBS(store, timeprovider.NewTimeProvider(), lager.NewLogger("auctioneer-bbs"))
+	}
+
+	actuals, err := d.bbs.GetAllActualLRPs()
+	if err != nil {
+		return nil, err
+	}
+
+	table := map[string]string{}
+	for _, actual := range actuals {
+		if strings.HasPrefix(actual.ProcessGuid, "rep-lite") && len(actual.Ports) == 1 {
+			addProcess(table, actual.ProcessGuid, fmt.Sprintf("http://%s:%d", actual.Host, actual.Ports[0].HostPort), d.repsPerProcess)
+		}
+	}
+	return table, nil
+}
+
+// fileRepDiscovery reads the reps of an address table, such as the one the
+// launcher writes.
+type fileRepDiscovery struct {
+	path string
+}
+
+func (d *fileRepDiscovery) Discover() (map[string]string, error) {
+	addresses, err := addresstable.Load(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	table := map[string]string{}
+	for _, repAddress := range addresses.Reps {
+		table[repAddress.RepGuid] = repAddress.Address
+	}
+	return table, nil
+}
+
+// listRepDiscovery parses guid=address pairs, e.g.
+// rep-lite-1=http://10.0.0.1:8080,rep-lite-2=http://10.0.0.2:8080
+type listRepDiscovery struct {
+	list string
+}
+
+func (d *listRepDiscovery) Discover() (map[string]string, error) {
+	table := map[string]string{}
+	for _, entry := range strings.Split(d.list, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid rep address: %s", entry)
+		}
+		table[parts[0]] = parts[1]
+	}
+	return table, nil
+}
+
+// srvRepDiscovery looks up a DNS SRV record with one target per rep-lite
+// process.  The process guid is the first label of the target, so
+// rep-lite-1.reps.example.com:8080 is rep-lite-1.
+type srvRepDiscovery struct {
+	name           string
+	repsPerProcess int
+}
+
+func (d *srvRepDiscovery) Discover() (map[string]string, error) {
+	_, records, err := net.LookupSRV("", "", d.name)
+	if err != nil {
+		return nil, err
+	}
+
+	table := map[string]string{}
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		processGuid := strings.SplitN(target, ".", 2)[0]
+		addProcess(table, processGuid, fmt.Sprintf("http://%s:%d", target, record.Port), d.repsPerProcess)
+	}
+	return table, nil
+}
@@ -1,6 +1,7 @@
 GOOS=linux GOARCH=amd64 go build .
 GOOS=linux GOARCH=amd64 ginkgo build
-tar -zcf auctionscenarios.tar.gz auctionscenarios auctionscenarios.test
+GOOS=linux GOARCH=amd64 go build -o launcher/launcher ./launcher
+tar -zcf auctionscenarios.tar.gz auctionscenarios auctionscenarios.test launcher/launcher scenarios
 source ~/.bashisms/s3_upload.bash
 upload_to_s3 auctionscenarios.tar.gz
-rm auctionscenarios.tar.gz auctionscenarios auctionscenarios.test
\ No newline at end of file
+rm auctionscenarios.tar.gz auctionscenarios auctionscenarios.test launcher/launcher
\ No newline at end of file
@@ -0,0 +1,168 @@
+package cellmix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+)
+
+// A CellClass is a cell size along with the fraction of cells that have it.
+type CellClass struct {
+	Fraction  float64
+	Resources auctiontypes.Resources
+}
+
+// A Mix describes the cell sizes of a cluster.  Each rep picks its class by
+// its index in the cluster, which both the reps and the suite derive from the
+// rep's guid, so they agree on a rep's size without talking to each other.
+type Mix []CellClass
+
+// Parse reads a mix such as "0.7:100,0.3:400/200/50", where each class is
+// fraction:memoryMB[/diskMB[/containers]].  Disk and containers default to the
+// memory.
+func Parse(s string) (Mix, error) {
+	mix := Mix{}
+	for _, class := range strings.Split(s, ",") {
+		parts := strings.SplitN(class, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cell class: %s", class)
+		}
+		fraction, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fraction in cell class %s: %s", class, err.Error())
+		}
+
+		sizes := []int{}
+		for _, size := range strings.Split(parts[1], "/") {
+			n, err := strconv.Atoi(size)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size in cell class %s: %s", class, err.Error())
+			}
+			sizes = append(sizes, n)
+		}
+		if len(sizes) > 3 {
+			return nil, fmt.Errorf("too many sizes in cell class: %s", class)
+		}
+		for len(sizes) < 3 {
+			sizes = append(sizes, sizes[0])
+		}
+
+		mix = append(mix, CellClass{
+			Fraction: fraction,
+			Resources: auctiontypes.Resources{
+				MemoryMB:   sizes[0],
+				DiskMB:     sizes[1],
+				Containers: sizes[2],
+			},
+		})
+	}
+
+	return mix, mix.Validate()
+}
+
+// Fetch downloads a mix, encoded as JSON, from a capacity profile URL.
+func Fetch(url string) (Mix, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d fetching %s", res.StatusCode, url)
+	}
+
+	mix := Mix{}
+	err = json.NewDecoder(res.Body).Decode(&mix)
+	if err != nil {
+		return nil, err
+	}
+	return mix, mix.Validate()
+}
+
+func (m Mix) Validate() error {
+	if len(m) == 0 {
+		return errors.New("a cell mix needs at least one class")
+	}
+
+	total := 0.0
+	for _, class := range m {
+		if class.Fraction <= 0 {
+			return fmt.Errorf("cell class fractions must be positive, got %f", class.Fraction)
+		}
+		if class.Resources.MemoryMB <= 0 || class.Resources.DiskMB <= 0 || class.Resources.Containers <= 0 {
+			return fmt.Errorf("cell class resources must be positive, got %+v", class.Resources)
+		}
+		total += class.Fraction
+	}
+	if math.Abs(total-1) > 0.001 {
+		return fmt.Errorf("cell class fractions must add up to 1, got %f", total)
+	}
+	return nil
+}
+
+// ClassFor deals the classes out to the cells in index order, each cell going
+// to the class furthest behind its share, so that the first n cells of any
+// cluster hold within a cell of Fraction*n of every class.
+func (m Mix) ClassFor(index int) int {
+	counts := make([]int, len(m))
+	class := 0
+	for n := 1; n <= index+1; n++ {
+		class = 0
+		for c := range m {
+			if m[c].Fraction*float64(n)-float64(counts[c]) > m[class].Fraction*float64(n)-float64(counts[class]) {
+				class = c
+			}
+		}
+		counts[class]++
+	}
+	return class
+}
+
+func (m Mix) ResourcesFor(index int) auctiontypes.Resources {
+	return m[m.ClassFor(index)].Resources
+}
+
+// CellIndex numbers reps from 0 in the order the launcher and the suite lay
+// them out: rep-lite-N is cell N-1, and with several reps per process
+// rep-lite-N-M is cell (N-1)*repsPerProcess+M-1.
+func CellIndex(repGuid string, repsPerProcess int) (int, error) {
+	labels := strings.Split(strings.TrimPrefix(repGuid, "rep-lite-"), "-")
+	wantLabels := 1
+	if repsPerProcess > 1 {
+		wantLabels = 2
+	}
+	if !strings.HasPrefix(repGuid, "rep-lite-") || len(labels) != wantLabels {
+		return 0, fmt.Errorf("can't tell the cell index of %s", repGuid)
+	}
+
+	numbers := []int{}
+	for _, label := range labels {
+		n, err := strconv.Atoi(label)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("can't tell the cell index of %s", repGuid)
+		}
+		numbers = append(numbers, n)
+	}
+
+	if len(numbers) == 1 {
+		return numbers[0] - 1, nil
+	}
+	if numbers[1] > repsPerProcess {
+		return 0, fmt.Errorf("%s is beyond %d reps per process", repGuid, repsPerProcess)
+	}
+	return (numbers[0]-1)*repsPerProcess + numbers[1] - 1, nil
+}
+
+func (m Mix) String() string {
+	classes := []string{}
+	for _, class := range m {
+		classes = append(classes, fmt.Sprintf("%g:%d/%d/%d", class.Fraction, class.Resources.MemoryMB, class.Resources.DiskMB, class.Resources.Containers))
+	}
+	return strings.Join(classes, ",")
+}
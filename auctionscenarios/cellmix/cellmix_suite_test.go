@@ -0,0 +1,13 @@
+package cellmix_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCellMix(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cell Mix Suite")
+}
@@ -0,0 +1,121 @@
+package cellmix_test
+
+import (
+	"math"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/cellmix"
+)
+
+var _ = Describe("Mix", func() {
+	Describe("Parse", func() {
+		It("parses fractions of memory/disk/containers, defaulting disk and containers to the memory", func() {
+			mix, err := cellmix.Parse("0.7:100,0.3:400/200/50")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(mix).Should(Equal(cellmix.Mix{
+				{Fraction: 0.7, Resources: auctiontypes.Resources{MemoryMB: 100, DiskMB: 100, Containers: 100}},
+				{Fraction: 0.3, Resources: auctiontypes.Resources{MemoryMB: 400, DiskMB: 200, Containers: 50}},
+			}))
+
+			mix, err = cellmix.Parse("0.5:64/128,0.5:256")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(mix).Should(Equal(cellmix.Mix{
+				{Fraction: 0.5, Resources: auctiontypes.Resources{MemoryMB: 64, DiskMB: 128, Containers: 64}},
+				{Fraction: 0.5, Resources: auctiontypes.Resources{MemoryMB: 256, DiskMB: 256, Containers: 256}},
+			}))
+		})
+
+		It("parses its own String", func() {
+			mix, err := cellmix.Parse("0.7:100,0.3:400/200/50")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			reparsed, err := cellmix.Parse(mix.String())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reparsed).Should(Equal(mix))
+		})
+
+		It("rejects malformed classes", func() {
+			for _, description := range []string{"", "100", "half:100", "1:big", "1:100/100/100/100", "1:0", "1:100/-1"} {
+				_, err := cellmix.Parse(description)
+				Ω(err).Should(HaveOccurred(), description)
+			}
+		})
+
+		It("rejects fractions that aren't positive or don't add up to 1", func() {
+			for _, description := range []string{"0.5:100", "0.5:100,0.6:200", "0:100,1:200"} {
+				_, err := cellmix.Parse(description)
+				Ω(err).Should(HaveOccurred(), description)
+			}
+		})
+	})
+
+	Describe("ClassFor", func() {
+		classesFor := func(description string, n int) []int {
+			mix, err := cellmix.Parse(description)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			classes := []int{}
+			for index := 0; index < n; index++ {
+				classes = append(classes, mix.ClassFor(index))
+			}
+			return classes
+		}
+
+		It("deals the classes out in proportion, spread across the cells", func() {
+			Ω(classesFor("0.5:100,0.5:200", 6)).Should(Equal([]int{0, 1, 0, 1, 0, 1}))
+			Ω(classesFor("0.7:100,0.3:400", 10)).Should(Equal([]int{0, 1, 0, 0, 0, 1, 0, 0, 1, 0}))
+			Ω(classesFor("0.25:64,0.5:128,0.25:256", 10)).Should(Equal([]int{1, 0, 2, 1, 1, 0, 2, 1, 1, 0}))
+		})
+
+		It("gives each cell the resources of its class", func() {
+			mix, err := cellmix.Parse("0.7:100,0.3:400")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(mix.ResourcesFor(1)).Should(Equal(mix[1].Resources))
+		})
+
+		It("keeps every prefix of the cells within a cell of the mix", func() {
+			mix, err := cellmix.Parse("0.6:100,0.3:200,0.1:400")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			counts := make([]int, len(mix))
+			for n := 1; n <= 200; n++ {
+				counts[mix.ClassFor(n-1)]++
+				for c, class := range mix {
+					Ω(math.Abs(float64(counts[c]) - class.Fraction*float64(n))).Should(BeNumerically("<=", 1))
+				}
+			}
+		})
+	})
+
+	Describe("CellIndex", func() {
+		cellIndex := func(repGuid string, repsPerProcess int) int {
+			index, err := cellmix.CellIndex(repGuid, repsPerProcess)
+			Ω(err).ShouldNot(HaveOccurred())
+			return index
+		}
+
+		It("numbers reps of single-rep processes by their process", func() {
+			Ω(cellIndex("rep-lite-1", 1)).Should(Equal(0))
+			Ω(cellIndex("rep-lite-12", 1)).Should(Equal(11))
+		})
+
+		It("numbers reps of multi-rep processes by process, then rep", func() {
+			Ω(cellIndex("rep-lite-1-1", 4)).Should(Equal(0))
+			Ω(cellIndex("rep-lite-1-4", 4)).Should(Equal(3))
+			Ω(cellIndex("rep-lite-3-2", 4)).Should(Equal(9))
+		})
+
+		It("rejects guids that don't match the reps per process", func() {
+			for _, repGuid := range []string{"cell-1", "rep-lite-", "rep-lite-0", "rep-lite-one", "rep-lite-1-2"} {
+				_, err := cellmix.CellIndex(repGuid, 1)
+				Ω(err).Should(HaveOccurred(), repGuid)
+			}
+			for _, repGuid := range []string{"rep-lite-1", "rep-lite-1-5", "rep-lite-1-0"} {
+				_, err := cellmix.CellIndex(repGuid, 4)
+				Ω(err).Should(HaveOccurred(), repGuid)
+			}
+		})
+	})
+})
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/addresstable"
+)
+
+var numCells = flag.Int("numCells", 10, "the number of reps to start")
+var repsPerProcess = flag.Int("repsPerProcess", 1, "how many reps each rep-lite process hosts, must divide -numCells")
+var numAuctioneers = flag.Int("numAuctioneers", 2, "the number of auctioneer-lite processes to start")
+var host = flag.String("host", "127.0.0.1", "the interface to listen on")
+var basePort = flag.Int("basePort", 9000, "the first port to use; rep-lites take the ports after it, then auctioneer-lites")
+var repLite = flag.String("repLite", "./rep-lite/rep-lite", "the rep-lite binary")
+var auctioneerLite = flag.String("auctioneerLite", "./auctioneer-lite/auctioneer-lite", "the auctioneer-lite binary")
+var repArgs = flag.String("repArgs", "", "space-separated flags to pass to every rep-lite, e.g. \"-cellMix=0.7:100,0.3:400 -zone=z1\"")
+var auctioneerArgs = flag.String("auctioneerArgs", "", "space-separated flags to pass to every auctioneer-lite")
+var addressTablePath = flag.String("addressTable", "./addresses.json", "where to write the address table for the suite's -addressTable and the auctioneers' file rep discovery")
+var startupTimeout = flag.Duration("startupTimeout", 10*time.Second, "how long to wait for every process to start listening")
+var shutdownTimeout = flag.Duration("shutdownTimeout", 5*time.Second, "how long to wait for the processes to exit before killing them")
+
+type process struct {
+	name    string
+	address string
+	cmd     *exec.Cmd
+	done    chan struct{}
+}
+
+func main() {
+	flag.Parse()
+
+	if *repsPerProcess < 1 || *numCells%*repsPerProcess != 0 {
+		log.Fatalln("-repsPerProcess must divide -numCells")
+	}
+
+	table := addresstable.Table{}
+	processes := []*process{}
+	port := *basePort
+
+	for i := 1; i <= *numCells / *repsPerProcess; i++ {
+		guid := fmt.Sprintf("rep-lite-%d", i)
+		address := fmt.Sprintf("%s:%d", *host, port)
+		port++
+
+		args := []string{"-repGuid=" + guid, "-listenAddress=" + address, fmt.Sprintf("-numReps=%d", *repsPerProcess)}
+		processes = append(processes, &process{
+			name:    guid,
+			address: address,
+			cmd:     exec.Command(*repLite, append(args, strings.Fields(*repArgs)...)...),
+			done:    make(chan struct{}),
+		})
+
+		if *repsPerProcess == 1 {
+			table.Reps = append(table.Reps, auctiontypes.RepAddress{
+				RepGuid: guid,
+				Address: "http://" + address,
+			})
+			continue
+		}
+		for j := 1; j <= *repsPerProcess; j++ {
+			repGuid := fmt.Sprintf("%s-%d", guid, j)
+			table.Reps = append(table.Reps, auctiontypes.RepAddress{
+				RepGuid: repGuid,
+				Address: "http://" + address + "/" + repGuid,
+			})
+		}
+	}
+
+	for i := 1; i <= *numAuctioneers; i++ {
+		guid := fmt.Sprintf("auctioneer-lite-%d", i)
+		address := fmt.Sprintf("%s:%d", *host, port)
+		port++
+
+		args := []string{"-auctioneerGuid=" + guid, "-listenAddress=" + address, "-repDiscovery=file", "-repAddressFile=" + *addressTablePath}
+		processes = append(processes, &process{
+			name:    guid,
+			address: address,
+			cmd:     exec.Command(*auctioneerLite, append(args, strings.Fields(*auctioneerArgs)...)...),
+			done:    make(chan struct{}),
+		})
+		table.Auctioneers = append(table.Auctioneers, addresstable.Auctioneer{
+			Guid: guid,
+			Host: address,
+		})
+	}
+
+	err := table.Write(*addressTablePath)
+	if err != nil {
+		log.Fatalln("failed to write address table:", err)
+	}
+	defer os.Remove(*addressTablePath)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	exited := make(chan *process, len(processes))
+
+	for _, p := range processes {
+		p.cmd.Stdout = os.Stdout
+		p.cmd.Stderr = os.Stderr
+		err = p.cmd.Start()
+		if err != nil {
+			fmt.Println("Failed to start", p.name, err.Error())
+			shutdown(processes)
+			os.Remove(*addressTablePath)
+			os.Exit(1)
+		}
+		p := p
+		go func() {
+			p.cmd.Wait()
+			close(p.done)
+			exited <- p
+		}()
+	}
+
+	err = waitUntilListening(processes, *startupTimeout)
+	if err != nil {
+		fmt.Println(err.Error())
+		shutdown(processes)
+		os.Remove(*addressTablePath)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d reps in %d rep-lites and %d auctioneer-lites are up, addresses in %s\n", *numCells, *numCells / *repsPerProcess, *numAuctioneers, *addressTablePath)
+
+	select {
+	case <-signals:
+	case p := <-exited:
+		fmt.Println(p.name, "exited, shutting down")
+	}
+
+	shutdown(processes)
+}
+
+func waitUntilListening(processes []*process, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, p := range processes {
+		for {
+			conn, err := net.DialTimeout("tcp", p.address, time.Second)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s did not start listening on %s: %s", p.name, p.address, err.Error())
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// shutdown asks every process to exit, then kills the ones that are still
+// around after -shutdownTimeout.
+func shutdown(processes []*process) {
+	for _, p := range processes {
+		if p.cmd.Process != nil {
+			p.cmd.Process.Signal(syscall.SIGTERM)
+		}
+	}
+
+	deadline := time.Now().Add(*shutdownTimeout)
+	for _, p := range processes {
+		if p.cmd.Process == nil {
+			continue
+		}
+		select {
+		case <-p.done:
+		case <-time.After(deadline.Sub(time.Now())):
+			fmt.Println("Killing", p.name)
+			p.cmd.Process.Kill()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ordering
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudfoundry-incubator/auction/util"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+const (
+	Generated     = "generated"
+	Shuffled      = "shuffled"
+	LargestFirst  = "largest-first"
+	SmallestFirst = "smallest-first"
+	Grouped       = "grouped"
+)
+
+var Names = []string{Generated, Shuffled, LargestFirst, SmallestFirst, Grouped}
+
+// Apply returns the start auctions in the order they are to be submitted:
+//
+//	generated      - as the scenario generated them
+//	shuffled       - randomly, from util.R
+//	largest-first  - by memory, then disk, largest first
+//	smallest-first - by memory, then disk, smallest first
+//	grouped        - the instances of each app together, apps in the order
+//	                 they first appear
+func Apply(name string, startAuctions []models.LRPStartAuction) ([]models.LRPStartAuction, error) {
+	ordered := make([]models.LRPStartAuction, len(startAuctions))
+	copy(ordered, startAuctions)
+
+	switch name {
+	case "", Generated:
+	case Shuffled:
+		for i, index := range util.R.Perm(len(startAuctions)) {
+			ordered[i] = startAuctions[index]
+		}
+	case LargestFirst:
+		sort.Stable(bySize{ordered, true})
+	case SmallestFirst:
+		sort.Stable(bySize{ordered, false})
+	case Grouped:
+		groups := map[string][]models.LRPStartAuction{}
+		processGuids := []string{}
+		for _, startAuction := range startAuctions {
+			processGuid := startAuction.DesiredLRP.ProcessGuid
+			if _, ok := groups[processGuid]; !ok {
+				processGuids = append(processGuids, processGuid)
+			}
+			groups[processGuid] = append(groups[processGuid], startAuction)
+		}
+		ordered = ordered[:0]
+		for _, processGuid := range processGuids {
+			ordered = append(ordered, groups[processGuid]...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown ordering: %s", name)
+	}
+
+	return ordered, nil
+}
+
+type bySize struct {
+	startAuctions []models.LRPStartAuction
+	largestFirst  bool
+}
+
+func (s bySize) Len() int { return len(s.startAuctions) }
+func (s bySize) Swap(i, j int) {
+	s.startAuctions[i], s.startAuctions[j] = s.startAuctions[j], s.startAuctions[i]
+}
+func (s bySize) Less(i, j int) bool {
+	a, b := s.startAuctions[i].DesiredLRP, s.startAuctions[j].DesiredLRP
+	if s.largestFirst {
+		a, b = b, a
+	}
+	if a.MemoryMB != b.MemoryMB {
+		return a.MemoryMB < b.MemoryMB
+	}
+	return a.DiskMB < b.DiskMB
+}
@@ -0,0 +1,13 @@
+package ordering_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOrdering(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ordering Suite")
+}
@@ -0,0 +1,80 @@
+package ordering_test
+
+import (
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/ordering"
+)
+
+var _ = Describe("Apply", func() {
+	startAuction := func(processGuid string, memoryMB int, diskMB int) models.LRPStartAuction {
+		return models.LRPStartAuction{
+			DesiredLRP:   models.DesiredLRP{ProcessGuid: processGuid, MemoryMB: memoryMB, DiskMB: diskMB},
+			InstanceGuid: processGuid + "-instance",
+		}
+	}
+
+	var startAuctions []models.LRPStartAuction
+
+	BeforeEach(func() {
+		startAuctions = []models.LRPStartAuction{
+			startAuction("a", 2, 2),
+			startAuction("b", 1, 1),
+			startAuction("a", 2, 2),
+			startAuction("c", 4, 1),
+			startAuction("b", 1, 3),
+			startAuction("d", 2, 1),
+		}
+	})
+
+	order := func(name string) []string {
+		ordered, err := ordering.Apply(name, startAuctions)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		guids := []string{}
+		for _, startAuction := range ordered {
+			guids = append(guids, startAuction.DesiredLRP.ProcessGuid)
+		}
+		return guids
+	}
+
+	It("keeps the generated order", func() {
+		Ω(order(ordering.Generated)).Should(Equal([]string{"a", "b", "a", "c", "b", "d"}))
+	})
+
+	It("orders by memory, then disk", func() {
+		Ω(order(ordering.LargestFirst)).Should(Equal([]string{"c", "a", "a", "d", "b", "b"}))
+		Ω(order(ordering.SmallestFirst)).Should(Equal([]string{"b", "b", "d", "a", "a", "c"}))
+
+		ordered, err := ordering.Apply(ordering.SmallestFirst, startAuctions)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ordered[0].DesiredLRP.DiskMB).Should(Equal(1))
+		Ω(ordered[1].DesiredLRP.DiskMB).Should(Equal(3))
+	})
+
+	It("groups the instances of each app, apps in the order they first appear", func() {
+		Ω(order(ordering.Grouped)).Should(Equal([]string{"a", "a", "b", "b", "c", "d"}))
+	})
+
+	It("shuffles every auction exactly once", func() {
+		counts := map[string]int{}
+		for _, processGuid := range order(ordering.Shuffled) {
+			counts[processGuid]++
+		}
+		Ω(counts).Should(Equal(map[string]int{"a": 2, "b": 2, "c": 1, "d": 1}))
+	})
+
+	It("leaves the generated auctions alone", func() {
+		for _, name := range ordering.Names {
+			order(name)
+		}
+		Ω(startAuctions[0].DesiredLRP.ProcessGuid).Should(Equal("a"))
+		Ω(startAuctions[5].DesiredLRP.ProcessGuid).Should(Equal("d"))
+	})
+
+	It("rejects unknown orderings", func() {
+		_, err := ordering.Apply("alphabetical", startAuctions)
+		Ω(err).Should(HaveOccurred())
+	})
+})
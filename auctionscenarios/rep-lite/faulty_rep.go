@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+const (
+	noFault        = "none"
+	killFault      = "kill"
+	freezeFault    = "freeze"
+	blackholeFault = "blackhole"
+)
+
+var errKilled = errors.New("rep has been killed")
+var errBlackholed = errors.New("rep has been blackholed")
+
+// faultyRep lets the simulation break a rep in the middle of a run.  Only the
+// auction calls are affected; the simulation calls (SimulatedInstances,
+// SetSimulatedInstances, TotalResources and Reset) always go through, and
+// Reset clears the fault.
+//
+//	kill      - auction calls fail immediately and the rep's instances are lost
+//	freeze    - auction calls hang until the fault is cleared, then go through
+//	blackhole - auction calls hang until the fault is cleared, then fail
+type faultyRep struct {
+	auctiontypes.SimulationAuctionRep
+
+	lock      *sync.Mutex
+	fault     string
+	cleared   chan struct{}
+	injection latencyInjection
+	random    *rand.Rand
+}
+
+func newFaultyRep(rep auctiontypes.SimulationAuctionRep, injection latencyInjection) *faultyRep {
+	return &faultyRep{
+		SimulationAuctionRep: rep,
+		lock:                 &sync.Mutex{},
+		fault:                noFault,
+		cleared:              make(chan struct{}),
+		injection:            injection,
+		random:               rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (r *faultyRep) SetFault(fault string) error {
+	switch fault {
+	case noFault, killFault, freezeFault, blackholeFault:
+	default:
+		return fmt.Errorf("unknown fault: %s", fault)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.fault != noFault {
+		close(r.cleared)
+		r.cleared = make(chan struct{})
+	}
+	r.fault = fault
+	if fault == killFault {
+		r.SimulationAuctionRep.SetSimulatedInstances([]auctiontypes.SimulatedInstance{})
+	}
+
+	return nil
+}
+
+func (r *faultyRep) Fault() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.fault
+}
+
+func (r *faultyRep) SetLatencyInjection(injection latencyInjection) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.injection = injection
+}
+
+func (r *faultyRep) LatencyInjection() latencyInjection {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.injection
+}
+
+// inject applies the current fault, then the injected latency.  Calls that
+// canFail then fail with the injected error probability.
+func (r *faultyRep) inject(canFail bool) error {
+	r.lock.Lock()
+	fault, cleared := r.fault, r.cleared
+	delay := r.injection.Delay(r.random)
+	failed := canFail && r.random.Float64() < r.injection.ErrorProbability
+	r.lock.Unlock()
+
+	switch fault {
+	case killFault:
+		return errKilled
+	case freezeFault:
+		<-cleared
+	case blackholeFault:
+		<-cleared
+		return errBlackholed
+	}
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if failed {
+		return errInjected
+	}
+	return nil
+}
+
+// ServeFault reports the current fault on GET and sets it on POST, e.g.
+// POST /fault?mode=freeze
+func (r *faultyRep) ServeFault(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		err := r.SetFault(req.URL.Query().Get("mode"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	fmt.Fprintln(w, r.Fault())
+}
+
+// ServeLatency reports the current latency injection on GET and updates it on
+// POST, e.g. POST /latency?distribution=long-tail&latency=5ms&jitter=50ms
+func (r *faultyRep) ServeLatency(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		injection, err := parseLatencyInjection(req, r.LatencyInjection())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+		r.SetLatencyInjection(injection)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(r.LatencyInjection())
+}
+
+// Reset clears the fault but keeps the latency injection, which describes the
+// cell rather than a single run.
+func (r *faultyRep) Reset() {
+	r.SetFault(noFault)
+	r.SimulationAuctionRep.Reset()
+}
+
+func (r *faultyRep) BidForStartAuction(startAuctionInfo auctiontypes.StartAuctionInfo) (float64, error) {
+	err := r.inject(true)
+	if err != nil {
+		return 0, err
+	}
+	return r.SimulationAuctionRep.BidForStartAuction(startAuctionInfo)
+}
+
+func (r *faultyRep) RebidThenTentativelyReserve(startAuctionInfo auctiontypes.StartAuctionInfo) (float64, error) {
+	err := r.inject(true)
+	if err != nil {
+		return 0, err
+	}
+	return r.SimulationAuctionRep.RebidThenTentativelyReserve(startAuctionInfo)
+}
+
+func (r *faultyRep) ReleaseReservation(startAuctionInfo auctiontypes.StartAuctionInfo) error {
+	err := r.inject(false)
+	if err != nil {
+		return err
+	}
+	return r.SimulationAuctionRep.ReleaseReservation(startAuctionInfo)
+}
+
+func (r *faultyRep) Run(startAuction models.LRPStartAuction) error {
+	err := r.inject(true)
+	if err != nil {
+		return err
+	}
+	return r.SimulationAuctionRep.Run(startAuction)
+}
+
+func (r *faultyRep) BidForStopAuction(stopAuctionInfo auctiontypes.StopAuctionInfo) (float64, []string, error) {
+	err := r.inject(true)
+	if err != nil {
+		return 0, nil, err
+	}
+	return r.SimulationAuctionRep.BidForStopAuction(stopAuctionInfo)
+}
+
+func (r *faultyRep) Stop(stopInstance models.StopLRPInstance) error {
+	err := r.inject(false)
+	if err != nil {
+		return err
+	}
+	return r.SimulationAuctionRep.Stop(stopInstance)
+}
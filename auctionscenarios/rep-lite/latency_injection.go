@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	noLatency       = "none"
+	fixedLatency    = "fixed"
+	uniformLatency  = "uniform"
+	longTailLatency = "long-tail"
+)
+
+// the shape of the pareto distribution behind long-tail latency; the lower
+// it is the fatter the tail
+const longTailShape = 1.5
+
+var errInjected = errors.New("injected error")
+
+// A latencyInjection slows down and fails the rep's auction calls.
+//
+//	fixed     - every call waits Latency
+//	uniform   - every call waits between Latency and Latency+Jitter
+//	long-tail - every call waits Latency plus a pareto distributed delay
+//	            scaled by Jitter, so most calls are quick and a few are slow
+//
+// Bids, rebids/reservations and claims (Run) additionally fail with
+// probability ErrorProbability.
+type latencyInjection struct {
+	Distribution     string
+	Latency          time.Duration
+	Jitter           time.Duration
+	ErrorProbability float64
+}
+
+func (l latencyInjection) Validate() error {
+	switch l.Distribution {
+	case noLatency, fixedLatency, uniformLatency, longTailLatency:
+	default:
+		return fmt.Errorf("unknown latency distribution: %s", l.Distribution)
+	}
+	if l.Latency < 0 || l.Jitter < 0 {
+		return errors.New("latency and jitter must not be negative")
+	}
+	if l.ErrorProbability < 0 || l.ErrorProbability > 1 {
+		return fmt.Errorf("error probability must be between 0 and 1, got %f", l.ErrorProbability)
+	}
+	return nil
+}
+
+func (l latencyInjection) Delay(r *rand.Rand) time.Duration {
+	switch l.Distribution {
+	case fixedLatency:
+		return l.Latency
+	case uniformLatency:
+		return l.Latency + time.Duration(r.Float64()*float64(l.Jitter))
+	case longTailLatency:
+		tail := math.Pow(1-r.Float64(), -1/longTailShape) - 1
+		return l.Latency + time.Duration(tail*float64(l.Jitter))
+	}
+	return 0
+}
+
+// parseLatencyInjection overrides the given injection with whichever of the
+// distribution, latency, jitter and errorProbability query parameters are set.
+func parseLatencyInjection(req *http.Request, l latencyInjection) (latencyInjection, error) {
+	query := req.URL.Query()
+
+	if query.Get("distribution") != "" {
+		l.Distribution = query.Get("distribution")
+	}
+	for name, value := range map[string]*time.Duration{"latency": &l.Latency, "jitter": &l.Jitter} {
+		if query.Get(name) == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(query.Get(name))
+		if err != nil {
+			return l, err
+		}
+		*value = duration
+	}
+	if query.Get("errorProbability") != "" {
+		probability, err := strconv.ParseFloat(query.Get("errorProbability"), 64)
+		if err != nil {
+			return l, err
+		}
+		l.ErrorProbability = probability
+	}
+
+	return l, l.Validate()
+}
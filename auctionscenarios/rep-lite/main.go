@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -21,14 +22,31 @@ import (
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
+
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/cellmix"
 )
 
 var repGuid = flag.String("repGuid", "", "rep-guid")
+var listenAddress = flag.String("listenAddress", "0.0.0.0:8080", "the address to serve the reps on")
+var numReps = flag.Int("numReps", 1, "the number of reps to host in this process; with more than one, rep i gets the guid <repGuid>-<i> and is served under /<repGuid>-<i>/")
 
 var natsUsername = flag.String("natsUsername", "", "nats username")
 var natsPassword = flag.String("natsPassword", "", "nats password")
 var natsAddresses = flag.String("natsAddresses", "", "nats addresses")
 
+var memoryMB = flag.Int("memoryMB", 100, "the memory capacity of the cell")
+var diskMB = flag.Int("diskMB", 100, "the disk capacity of the cell")
+var containers = flag.Int("containers", 100, "the container capacity of the cell")
+var cellMix = flag.String("cellMix", "", "a cell-size mix such as 0.7:100,0.3:400 to pick this cell's capacity from, overrides -memoryMB, -diskMB and -containers")
+var capacityProfile = flag.String("capacityProfile", "", "a URL serving a JSON cell-size mix to pick this cell's capacity from, overrides -cellMix")
+
+var zone = flag.String("zone", "", "the availability zone of the cell, served on /zone")
+
+var latencyDistribution = flag.String("latencyDistribution", noLatency, "latency added to every auction call, one of none, fixed, uniform or long-tail (can be changed at runtime through /latency)")
+var latency = flag.Duration("latency", 0, "the fixed latency, or the minimum latency for uniform and long-tail")
+var jitter = flag.Duration("jitter", 0, "the spread of uniform latency, or the scale of the long-tail")
+var errorProbability = flag.Float64("errorProbability", 0, "the probability that a bid, rebid/reserve or claim fails")
+
 func main() {
 	flag.Parse()
 
@@ -36,32 +54,113 @@ func main() {
 		panic("need rep-guid")
 	}
 
-	repDelegate := simulationrepdelegate.New(auctiontypes.Resources{
-		MemoryMB:   100,
-		DiskMB:     100,
-		Containers: 100,
-	})
-	rep := auctionrep.New(*repGuid, repDelegate)
+	injection := latencyInjection{
+		Distribution:     *latencyDistribution,
+		Latency:          *latency,
+		Jitter:           *jitter,
+		ErrorProbability: *errorProbability,
+	}
+	err := injection.Validate()
+	if err != nil {
+		log.Fatalln("invalid latency injection:", err)
+	}
+
+	reps := []*faultyRep{}
+	for _, guid := range repGuids() {
+		resources, err := cellResources(guid)
+		if err != nil {
+			log.Fatalln("failed to determine cell capacity:", err)
+		}
+		fmt.Printf("%s capacity: %+v\n", guid, resources)
 
-	go serveOverNATS(rep)
+		repDelegate := simulationrepdelegate.New(resources)
+		reps = append(reps, newFaultyRep(auctionrep.New(guid, repDelegate), injection))
+	}
 
-	handlers := auction_http_handlers.New(rep, lager.NewLogger("rep-lite-http"))
-	router, err := rata.NewRouter(routes.Routes, handlers)
-	if err != nil {
-		log.Fatalln("failed to make router:", err)
+	go serveOverNATS(reps)
+
+	var handler http.Handler
+	if len(reps) == 1 {
+		handler, err = repHandler(reps[0])
+		if err != nil {
+			log.Fatalln("failed to make router:", err)
+		}
+	} else {
+		mux := http.NewServeMux()
+		for _, rep := range reps {
+			prefix := "/" + rep.Guid()
+			h, err := repHandler(rep)
+			if err != nil {
+				log.Fatalln("failed to make router:", err)
+			}
+			mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+		}
+		handler = mux
 	}
-	httpServer := http_server.New("0.0.0.0:8080", router)
+	httpServer := http_server.New(*listenAddress, handler)
 
 	monitor := ifrit.Envoke(sigmon.New(httpServer))
 
-	fmt.Println("rep node listening")
+	fmt.Printf("%d rep(s) listening\n", len(reps))
 	err = <-monitor.Wait()
 	if err != nil {
 		println("EXITED WITH ERROR: ", err.Error())
 	}
 }
 
-func serveOverNATS(rep *auctionrep.AuctionRep) {
+func repGuids() []string {
+	if *numReps <= 1 {
+		return []string{*repGuid}
+	}
+	guids := []string{}
+	for i := 1; i <= *numReps; i++ {
+		guids = append(guids, fmt.Sprintf("%s-%d", *repGuid, i))
+	}
+	return guids
+}
+
+func repHandler(rep *faultyRep) (http.Handler, error) {
+	handlers := auction_http_handlers.New(rep, lager.NewLogger("rep-lite-http"))
+	router, err := rata.NewRouter(routes.Routes, handlers)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fault", rep.ServeFault)
+	mux.HandleFunc("/latency", rep.ServeLatency)
+	mux.HandleFunc("/zone", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, *zone)
+	})
+	mux.Handle("/", router)
+	return mux, nil
+}
+
+func cellResources(guid string) (auctiontypes.Resources, error) {
+	var mix cellmix.Mix
+	var err error
+	if *capacityProfile != "" {
+		mix, err = cellmix.Fetch(*capacityProfile)
+	} else if *cellMix != "" {
+		mix, err = cellmix.Parse(*cellMix)
+	} else {
+		return auctiontypes.Resources{
+			MemoryMB:   *memoryMB,
+			DiskMB:     *diskMB,
+			Containers: *containers,
+		}, nil
+	}
+	if err != nil {
+		return auctiontypes.Resources{}, err
+	}
+
+	index, err := cellmix.CellIndex(guid, *numReps)
+	if err != nil {
+		return auctiontypes.Resources{}, err
+	}
+	return mix.ResourcesFor(index), nil
+}
+
+func serveOverNATS(reps []*faultyRep) {
 	if *natsAddresses != "" && *natsUsername != "" && *natsPassword != "" {
 		natsMembers := []string{}
 		for _, addr := range strings.Split(*natsAddresses, ",") {
@@ -78,7 +177,9 @@ func serveOverNATS(rep *auctionrep.AuctionRep) {
 			log.Fatalln("no nats:", err)
 		}
 
-		natsRunner := auction_nats_server.New(client, rep, lager.NewLogger("rep-lite-nats"))
-		ifrit.Envoke(sigmon.New(natsRunner))
+		for _, rep := range reps {
+			natsRunner := auction_nats_server.New(client, rep, lager.NewLogger("rep-lite-nats"))
+			ifrit.Envoke(sigmon.New(natsRunner))
+		}
 	}
 }
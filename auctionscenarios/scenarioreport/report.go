@@ -0,0 +1,160 @@
+package scenarioreport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/auction/simulation/visualization"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+)
+
+const StartAuctionKind = "start"
+const StopAuctionKind = "stop"
+const MixedWorkloadKind = "mixed"
+
+// The embedded Report holds the start auction results, if any, and the
+// instances the reps ended up with.  Stop auctions are summarized from
+// StopAuctionResults, in their own columns and in WriteStopReport's rows.
+type Report struct {
+	*visualization.Report
+	Scenario           string
+	Kind               string
+	Ordering           string
+	Seed               int64
+	ArrivalSchedule    string
+	StopAuctionResults []auctiontypes.StopAuctionResult
+	NDuplicatesBefore  int
+	NDuplicatesAfter   int
+	NEvacuatedCells    int
+	NUnplacedAuctions  int
+	FaultMode          string
+	NFaultyCells       int
+	Outcome            auctiondistributor.RunOutcome
+
+	NormalizedDistributionScore float64
+	ZoneBalance                 float64
+	InstancesByZone             map[string]int
+}
+
+func (r *Report) RoundStats() (float64, int) {
+	if len(r.AuctionResults) == 0 {
+		return 0, 0
+	}
+
+	total, max := 0, 0
+	for _, result := range r.AuctionResults {
+		total += result.NumRounds
+		if result.NumRounds > max {
+			max = result.NumRounds
+		}
+	}
+	return float64(total) / float64(len(r.AuctionResults)), max
+}
+
+func (r *Report) NDuplicatesStopped() int {
+	return r.NDuplicatesBefore - r.NDuplicatesAfter
+}
+
+// KeptByRep counts, for each rep, the stop auctions it won and so kept its
+// instance for.
+func (r *Report) KeptByRep() map[string]int {
+	keptByRep := map[string]int{}
+	for _, result := range r.StopAuctionResults {
+		if result.Winner != "" {
+			keptByRep[result.Winner]++
+		}
+	}
+	return keptByRep
+}
+
+// StopAuctionStats returns the number of stop auctions, their communications
+// and the longest one, in seconds.
+func (r *Report) StopAuctionStats() (int, int, float64) {
+	communication, maxWait := 0, time.Duration(0)
+	for _, result := range r.StopAuctionResults {
+		communication += result.NumCommunications
+		if result.Duration > maxWait {
+			maxWait = result.Duration
+		}
+	}
+	return len(r.StopAuctionResults), communication, maxWait.Seconds()
+}
+
+// CountUnplacedStartAuctions counts the auctions that found no rep along with
+// those that never came back at all.
+func CountUnplacedStartAuctions(results []auctiontypes.StartAuctionResult, outcome auctiondistributor.RunOutcome) int {
+	n := len(outcome.LostStartAuctions)
+	for _, result := range results {
+		if result.Winner == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// CountDuplicateInstances counts the instances beyond the first of every
+// process index.
+func CountDuplicateInstances(instancesByRep map[string][]auctiontypes.SimulatedInstance) int {
+	counts := map[string]int{}
+	for _, instances := range instancesByRep {
+		for _, instance := range instances {
+			counts[fmt.Sprintf("%s.%d", instance.ProcessGuid, instance.Index)]++
+		}
+	}
+
+	nDuplicates := 0
+	for _, count := range counts {
+		if count > 1 {
+			nDuplicates += count - 1
+		}
+	}
+	return nDuplicates
+}
+
+// NormalizeByCapacity thins out each rep's instances in proportion to its
+// memory, relative to the smallest rep, so that its distribution score
+// compares how full the reps are rather than how many instances they hold.
+// It is only used for the normalized score: the thinned instances aren't the
+// ones the reps hold, so the report card is drawn from the raw report.
+// Reports on uniform clusters are returned unchanged.
+func NormalizeByCapacity(report *visualization.Report, capacities map[string]auctiontypes.Resources) *visualization.Report {
+	smallest := 0
+	uniform := true
+	for _, resources := range capacities {
+		if smallest != 0 && resources.MemoryMB != smallest {
+			uniform = false
+		}
+		if smallest == 0 || resources.MemoryMB < smallest {
+			smallest = resources.MemoryMB
+		}
+	}
+	if uniform || smallest == 0 {
+		return report
+	}
+
+	instancesByRep := map[string][]auctiontypes.SimulatedInstance{}
+	for repGuid, instances := range report.InstancesByRep {
+		capacity := capacities[repGuid].MemoryMB
+		if capacity == 0 {
+			instancesByRep[repGuid] = instances
+			continue
+		}
+
+		fraction := float64(smallest) / float64(capacity)
+		normalized := []auctiontypes.SimulatedInstance{}
+		for i, instance := range instances {
+			if int(float64(i+1)*fraction) > int(float64(i)*fraction) {
+				normalized = append(normalized, instance)
+			}
+		}
+		instancesByRep[repGuid] = normalized
+	}
+
+	return &visualization.Report{
+		RepAddresses:    report.RepAddresses,
+		AuctionResults:  report.AuctionResults,
+		InstancesByRep:  instancesByRep,
+		AuctionDuration: report.AuctionDuration,
+	}
+}
@@ -0,0 +1,122 @@
+package scenarioreport_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/auction/simulation/visualization"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenarioreport"
+)
+
+func instances(processGuid string, n int) []auctiontypes.SimulatedInstance {
+	simulatedInstances := []auctiontypes.SimulatedInstance{}
+	for i := 0; i < n; i++ {
+		simulatedInstances = append(simulatedInstances, auctiontypes.SimulatedInstance{ProcessGuid: processGuid, Index: i, MemoryMB: 1})
+	}
+	return simulatedInstances
+}
+
+var _ = Describe("Report", func() {
+	It("averages the rounds of the start auctions", func() {
+		report := &scenarioreport.Report{Report: &visualization.Report{
+			AuctionResults: []auctiontypes.StartAuctionResult{{NumRounds: 1}, {NumRounds: 4}, {NumRounds: 1}},
+		}}
+		mean, max := report.RoundStats()
+		Ω(mean).Should(Equal(2.0))
+		Ω(max).Should(Equal(4))
+	})
+
+	It("has no rounds without start auctions", func() {
+		report := &scenarioreport.Report{Report: &visualization.Report{}}
+		mean, max := report.RoundStats()
+		Ω(mean).Should(Equal(0.0))
+		Ω(max).Should(Equal(0))
+	})
+
+	It("summarizes the stop auctions", func() {
+		report := &scenarioreport.Report{
+			Report: &visualization.Report{},
+			StopAuctionResults: []auctiontypes.StopAuctionResult{
+				{Winner: "rep-lite-1", NumCommunications: 3, Duration: time.Second},
+				{Winner: "rep-lite-2", NumCommunications: 2, Duration: 3 * time.Second},
+				{Winner: "rep-lite-1", NumCommunications: 4, Duration: 2 * time.Second},
+				{NumCommunications: 1, Duration: time.Second},
+			},
+			NDuplicatesBefore: 10,
+			NDuplicatesAfter:  3,
+		}
+
+		nStopAuctions, communication, maxWait := report.StopAuctionStats()
+		Ω(nStopAuctions).Should(Equal(4))
+		Ω(communication).Should(Equal(10))
+		Ω(maxWait).Should(Equal(3.0))
+
+		Ω(report.KeptByRep()).Should(Equal(map[string]int{"rep-lite-1": 2, "rep-lite-2": 1}))
+		Ω(report.NDuplicatesStopped()).Should(Equal(7))
+	})
+
+	It("counts every copy of an index after the first as a duplicate", func() {
+		Ω(scenarioreport.CountDuplicateInstances(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": instances("a", 3),
+			"rep-lite-2": instances("b", 3),
+		})).Should(Equal(0))
+		Ω(scenarioreport.CountDuplicateInstances(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": instances("a", 2),
+			"rep-lite-2": instances("a", 2),
+			"rep-lite-3": instances("a", 1),
+		})).Should(Equal(3))
+		Ω(scenarioreport.CountDuplicateInstances(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": append(instances("a", 1), instances("a", 1)...),
+		})).Should(Equal(1))
+	})
+
+	It("counts start auctions without a winner and lost ones as unplaced", func() {
+		results := []auctiontypes.StartAuctionResult{{Winner: "rep-lite-1"}, {}, {Winner: "rep-lite-2"}, {}}
+		outcome := auctiondistributor.RunOutcome{LostStartAuctions: []models.LRPStartAuction{{InstanceGuid: "lost"}}}
+		Ω(scenarioreport.CountUnplacedStartAuctions(results, outcome)).Should(Equal(3))
+	})
+
+	Describe("NormalizeByCapacity", func() {
+		var report *visualization.Report
+
+		BeforeEach(func() {
+			report = &visualization.Report{
+				InstancesByRep: map[string][]auctiontypes.SimulatedInstance{
+					"small":   instances("a", 10),
+					"large":   instances("b", 40),
+					"unknown": instances("c", 5),
+				},
+				AuctionDuration: time.Minute,
+			}
+		})
+
+		It("leaves uniform clusters alone", func() {
+			normalized := scenarioreport.NormalizeByCapacity(report, map[string]auctiontypes.Resources{
+				"small": {MemoryMB: 100},
+				"large": {MemoryMB: 100},
+			})
+			Ω(normalized).Should(Equal(report))
+		})
+
+		It("leaves clusters of unknown capacity alone", func() {
+			Ω(scenarioreport.NormalizeByCapacity(report, map[string]auctiontypes.Resources{})).Should(Equal(report))
+		})
+
+		It("thins out the instances of larger reps in proportion to their memory", func() {
+			normalized := scenarioreport.NormalizeByCapacity(report, map[string]auctiontypes.Resources{
+				"small": {MemoryMB: 100},
+				"large": {MemoryMB: 400},
+			})
+			Ω(normalized.InstancesByRep["small"]).Should(HaveLen(10))
+			Ω(normalized.InstancesByRep["large"]).Should(HaveLen(10))
+			Ω(normalized.InstancesByRep["unknown"]).Should(HaveLen(5))
+			Ω(normalized.AuctionDuration).Should(Equal(time.Minute))
+
+			Ω(report.InstancesByRep["large"]).Should(HaveLen(40))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package scenarioreport_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestScenarioReport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scenario Report Suite")
+}
@@ -0,0 +1,56 @@
+package scenarioreport
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ajstarks/svgo"
+)
+
+// WriteStopReport draws a row for every report that held stop auctions: the
+// duplicates stopped and remaining, and the stop auctions' own statistics.
+// Nothing is written if none did.
+func WriteStopReport(path string, reports []*Report) error {
+	stopReports := []*Report{}
+	for _, report := range reports {
+		if report.Kind == StopAuctionKind || report.Kind == MixedWorkloadKind {
+			stopReports = append(stopReports, report)
+		}
+	}
+	if len(stopReports) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	const rowHeight = 30
+	const labelWidth = 220
+	const barWidth = 300
+	canvas := svg.New(f)
+	canvas.Start(labelWidth+2*barWidth+40, rowHeight*(len(stopReports)+2))
+	canvas.Text(10, 20, "duplicates stopped (green) and remaining (red)", "font-family:sans-serif;font-size:14px")
+	canvas.Text(labelWidth+barWidth+20, 20, "stop auctions", "font-family:sans-serif;font-size:14px")
+
+	for i, report := range stopReports {
+		y := (i + 1) * rowHeight
+		canvas.Text(10, y+18, report.Scenario, "font-family:sans-serif;font-size:12px")
+		canvas.Rect(labelWidth, y+4, barWidth, rowHeight-8, "fill:none;stroke:black")
+		if report.NDuplicatesBefore > 0 {
+			stopped := barWidth * report.NDuplicatesStopped() / report.NDuplicatesBefore
+			remaining := barWidth * report.NDuplicatesAfter / report.NDuplicatesBefore
+			canvas.Rect(labelWidth, y+4, stopped, rowHeight-8, "fill:seagreen")
+			canvas.Rect(labelWidth+stopped, y+4, remaining, rowHeight-8, "fill:firebrick")
+		}
+		canvas.Text(labelWidth+4, y+18, fmt.Sprintf("%d/%d", report.NDuplicatesStopped(), report.NDuplicatesBefore), "font-family:sans-serif;font-size:12px;fill:white")
+
+		nStopAuctions, communication, maxWait := report.StopAuctionStats()
+		canvas.Text(labelWidth+barWidth+20, y+18, fmt.Sprintf("%d auctions, %d communications, %.2fs max wait, %d keeping reps", nStopAuctions, communication, maxWait, len(report.KeptByRep())), "font-family:sans-serif;font-size:12px")
+	}
+
+	canvas.End()
+	return nil
+}
@@ -0,0 +1,117 @@
+package scenarioreport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+)
+
+const SummaryHeader = "numCells,numAuctioneers,concurrentAuctionsPerAuctioneer,maxBiddingPoolFraction,algorithm,scenario,# auctions,communication,waitTime,biddingTime,distributionScore,nMissing,nFailedAuctioneers,nLostAuctions,partitionStrategy,arrivalSchedule,kind,nDuplicatesStopped,nDuplicatesRemaining,nKeepingReps,nEvacuatedCells,nUnplaced,faultMode,nFaultyCells,meanRounds,maxRounds,repLatency,cellMix,normalizedDistributionScore,nZones,zoneBalance,seed,ordering,nStopAuctions,stopCommunication,stopWaitTime,distributor,communicationMode\n"
+
+// A Run holds the summary columns shared by every report of a suite run.
+type Run struct {
+	NumCells                        int
+	NumAuctioneers                  int
+	ConcurrentAuctionsPerAuctioneer int
+	Rules                           auctiontypes.StartAuctionRules
+	PartitionStrategy               string
+	RepLatency                      string
+	CellMix                         string
+	NZones                          int
+	Distributor                     string
+	CommunicationMode               string
+}
+
+// SummaryRow formats the report as a line of the summary, in SummaryHeader's
+// columns.
+func SummaryRow(run Run, report *Report) string {
+	meanRounds, maxRounds := report.RoundStats()
+	nStopAuctions, stopCommunication, stopWaitTime := report.StopAuctionStats()
+	return fmt.Sprintf("%d,%d,%d,%.2f,%s,%s,%d,%d,%.2f,%.2f,%.4f,%d,%d,%d,%s,%s,%s,%d,%d,%d,%d,%d,%s,%d,%.2f,%d,%s,%s,%.4f,%d,%s,%d,%s,%d,%d,%.2f,%s,%s\n",
+		run.NumCells,
+		run.NumAuctioneers,
+		run.ConcurrentAuctionsPerAuctioneer,
+		run.Rules.MaxBiddingPoolFraction,
+		run.Rules.Algorithm,
+		report.Scenario,
+		report.NAuctions(),
+		int64(report.CommStats().Total),
+		report.WaitTimeStats().Max,
+		report.BiddingTimeStats().Max,
+		report.DistributionScore(),
+		report.NMissingInstances(),
+		report.Outcome.NFailedAuctioneers(),
+		report.Outcome.NLostAuctions(),
+		run.PartitionStrategy,
+		report.ArrivalSchedule,
+		report.Kind,
+		report.NDuplicatesStopped(),
+		report.NDuplicatesAfter,
+		len(report.KeptByRep()),
+		report.NEvacuatedCells,
+		report.NUnplacedAuctions,
+		faultModeName(report.FaultMode),
+		report.NFaultyCells,
+		meanRounds,
+		maxRounds,
+		run.RepLatency,
+		run.CellMix,
+		report.NormalizedDistributionScore,
+		run.NZones,
+		zoneBalanceColumn(run, report),
+		report.Seed,
+		report.Ordering,
+		nStopAuctions,
+		stopCommunication,
+		stopWaitTime,
+		run.Distributor,
+		run.CommunicationMode,
+	)
+}
+
+// AppendToSummary adds a row for every report to the summary at path.
+func AppendToSummary(path string, run Run, reports []*Report) error {
+	summary := LoadSummary(path)
+	for _, report := range reports {
+		summary += SummaryRow(run, report)
+	}
+	return ioutil.WriteFile(path, []byte(summary), 0666)
+}
+
+// LoadSummary returns the existing summary so new rows can be appended to it.
+// A summary written with different columns is moved aside rather than mixed
+// with the new rows.
+func LoadSummary(path string) string {
+	summaryBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SummaryHeader
+	}
+
+	summary := string(summaryBytes)
+	if !strings.HasPrefix(summary, SummaryHeader) {
+		os.Rename(path, fmt.Sprintf("%s.%d", path, time.Now().Unix()))
+		return SummaryHeader
+	}
+
+	return summary
+}
+
+// zoneBalanceColumn leaves the zone balance blank on clusters without zones,
+// where it would always read 1.
+func zoneBalanceColumn(run Run, report *Report) string {
+	if run.NZones < 2 {
+		return ""
+	}
+	return fmt.Sprintf("%.4f", report.ZoneBalance)
+}
+
+func faultModeName(mode string) string {
+	if mode == "" {
+		return "none"
+	}
+	return mode
+}
@@ -0,0 +1,186 @@
+package scenarioreport_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/auction/simulation/visualization"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenarioreport"
+)
+
+var _ = Describe("Summary", func() {
+	var dir string
+	var path string
+	var run scenarioreport.Run
+	var report *scenarioreport.Report
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scenarioreport")
+		Ω(err).ShouldNot(HaveOccurred())
+		path = filepath.Join(dir, "summary.csv")
+
+		run = scenarioreport.Run{
+			NumCells:                        100,
+			NumAuctioneers:                  10,
+			ConcurrentAuctionsPerAuctioneer: 20,
+			Rules:                           auctiontypes.StartAuctionRules{Algorithm: "all_rebid", MaxBiddingPoolFraction: 0.2},
+			PartitionStrategy:               "round-robin",
+			RepLatency:                      "none",
+			CellMix:                         "uniform",
+			NZones:                          2,
+			Distributor:                     "nats",
+			CommunicationMode:               "HTTP",
+		}
+		report = &scenarioreport.Report{
+			Report:   &visualization.Report{AuctionResults: []auctiontypes.StartAuctionResult{{NumRounds: 2}}},
+			Scenario: "duplicates with 10% start",
+			Kind:     scenarioreport.MixedWorkloadKind,
+			Ordering: "grouped",
+			Seed:     42,
+			StopAuctionResults: []auctiontypes.StopAuctionResult{
+				{Winner: "rep-lite-1", NumCommunications: 3, Duration: 1500 * time.Millisecond},
+			},
+			NDuplicatesBefore: 5,
+			NDuplicatesAfter:  1,
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	columns := func(line string) map[string]string {
+		header := strings.Split(strings.TrimSpace(scenarioreport.SummaryHeader), ",")
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		Ω(fields).Should(HaveLen(len(header)))
+
+		values := map[string]string{}
+		for i, name := range header {
+			values[name] = fields[i]
+		}
+		return values
+	}
+
+	It("writes a row in the header's columns", func() {
+		row := columns(scenarioreport.SummaryRow(run, report))
+
+		expected := map[string]string{
+			"numCells":               "100",
+			"numAuctioneers":         "10",
+			"maxBiddingPoolFraction": "0.20",
+			"algorithm":              "all_rebid",
+			"scenario":               "duplicates with 10% start",
+			"partitionStrategy":      "round-robin",
+			"kind":                   "mixed",
+			"nDuplicatesStopped":     "4",
+			"nDuplicatesRemaining":   "1",
+			"nKeepingReps":           "1",
+			"faultMode":              "none",
+			"meanRounds":             "2.00",
+			"maxRounds":              "2",
+			"cellMix":                "uniform",
+			"nZones":                 "2",
+			"seed":                   "42",
+			"ordering":               "grouped",
+			"nStopAuctions":          "1",
+			"stopCommunication":      "3",
+			"stopWaitTime":           "1.50",
+			"distributor":            "nats",
+			"communicationMode":      "HTTP",
+		}
+		for name, value := range expected {
+			Ω(row[name]).Should(Equal(value), name)
+		}
+	})
+
+	It("leaves the zone balance blank without zones", func() {
+		report.ZoneBalance = 1
+		Ω(columns(scenarioreport.SummaryRow(run, report))["zoneBalance"]).Should(Equal("1.0000"))
+
+		run.NZones = 1
+		Ω(columns(scenarioreport.SummaryRow(run, report))["zoneBalance"]).Should(BeEmpty())
+	})
+
+	It("starts a new summary with the header", func() {
+		err := scenarioreport.AppendToSummary(path, run, []*scenarioreport.Report{report, report})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		summary, err := ioutil.ReadFile(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(summary)), "\n")
+		Ω(lines).Should(HaveLen(3))
+		Ω(lines[0] + "\n").Should(Equal(scenarioreport.SummaryHeader))
+	})
+
+	It("appends to a summary with the same columns", func() {
+		err := scenarioreport.AppendToSummary(path, run, []*scenarioreport.Report{report})
+		Ω(err).ShouldNot(HaveOccurred())
+		err = scenarioreport.AppendToSummary(path, run, []*scenarioreport.Report{report})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		summary, err := ioutil.ReadFile(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(strings.Split(strings.TrimSpace(string(summary)), "\n")).Should(HaveLen(3))
+	})
+
+	It("moves a summary with other columns aside", func() {
+		err := ioutil.WriteFile(path, []byte("numCells,scenario\n100,cold start\n"), 0666)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(scenarioreport.LoadSummary(path)).Should(Equal(scenarioreport.SummaryHeader))
+
+		movedAside, err := filepath.Glob(path + ".*")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(movedAside).Should(HaveLen(1))
+		_, err = os.Stat(path)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("WriteStopReport", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "scenarioreport")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("draws the stop and mixed reports only", func() {
+		path := filepath.Join(dir, "report-stops.svg")
+		err := scenarioreport.WriteStopReport(path, []*scenarioreport.Report{
+			{Report: &visualization.Report{}, Scenario: "cold start", Kind: scenarioreport.StartAuctionKind},
+			{Report: &visualization.Report{}, Scenario: "duplicates", Kind: scenarioreport.StopAuctionKind, NDuplicatesBefore: 4, NDuplicatesAfter: 1},
+			{Report: &visualization.Report{}, Scenario: "duplicates with 10% start", Kind: scenarioreport.MixedWorkloadKind},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		svg, err := ioutil.ReadFile(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(svg)).ShouldNot(ContainSubstring("cold start"))
+		Ω(string(svg)).Should(ContainSubstring("duplicates with 10% start"))
+		Ω(string(svg)).Should(ContainSubstring("3/4"))
+	})
+
+	It("writes nothing without stop auctions", func() {
+		path := filepath.Join(dir, "report-stops.svg")
+		err := scenarioreport.WriteStopReport(path, []*scenarioreport.Report{
+			{Report: &visualization.Report{}, Scenario: "cold start", Kind: scenarioreport.StartAuctionKind},
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = os.Stat(path)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+})
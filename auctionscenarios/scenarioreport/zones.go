@@ -0,0 +1,125 @@
+package scenarioreport
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ajstarks/svgo"
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+)
+
+// Zones returns the zones of zoneOf, a rep guid to zone map, in name order.
+func Zones(zoneOf map[string]string) []string {
+	set := map[string]bool{}
+	for _, zone := range zoneOf {
+		set[zone] = true
+	}
+
+	zones := []string{}
+	for zone := range set {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// ZoneBalance returns the fraction of processes with more than one instance
+// whose instances are spread evenly across the zones, i.e. no zone has more
+// than one instance more than any other, along with the number of instances
+// in each zone.  With fewer than two zones every process is trivially
+// balanced, so the balance only means something on zoned clusters.
+func ZoneBalance(instancesByRep map[string][]auctiontypes.SimulatedInstance, zoneOf map[string]string) (float64, map[string]int) {
+	allZones := Zones(zoneOf)
+
+	instancesByZone := map[string]int{}
+	countsByProcess := map[string]map[string]int{}
+	for repGuid, instances := range instancesByRep {
+		zone := zoneOf[repGuid]
+		for _, instance := range instances {
+			instancesByZone[zone]++
+			if countsByProcess[instance.ProcessGuid] == nil {
+				countsByProcess[instance.ProcessGuid] = map[string]int{}
+			}
+			countsByProcess[instance.ProcessGuid][zone]++
+		}
+	}
+
+	nProcesses, nBalanced := 0, 0
+	for _, counts := range countsByProcess {
+		total, min, max := 0, -1, 0
+		for _, zone := range allZones {
+			count := counts[zone]
+			total += count
+			if min == -1 || count < min {
+				min = count
+			}
+			if count > max {
+				max = count
+			}
+		}
+		if total < 2 {
+			continue
+		}
+		nProcesses++
+		if max-min <= 1 {
+			nBalanced++
+		}
+	}
+
+	if nProcesses == 0 {
+		return 1, instancesByZone
+	}
+	return float64(nBalanced) / float64(nProcesses), instancesByZone
+}
+
+// WriteZoneReport draws the zone balance and the instances per zone of every
+// report, to go next to the main report.  Nothing is written with fewer than
+// two zones.
+func WriteZoneReport(path string, reports []*Report, zones []string) error {
+	if len(zones) < 2 {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	colors := []string{"steelblue", "darkorange", "seagreen", "firebrick", "mediumpurple", "sienna", "hotpink", "gray"}
+
+	const rowHeight = 30
+	const labelWidth = 220
+	const barWidth = 300
+	canvas := svg.New(f)
+	canvas.Start(labelWidth+2*barWidth+40, rowHeight*(len(reports)+2))
+	canvas.Text(10, 20, "zone balance", "font-family:sans-serif;font-size:14px")
+	canvas.Text(labelWidth+barWidth+20, 20, "instances per zone: "+strings.Join(zones, ", "), "font-family:sans-serif;font-size:14px")
+
+	for i, report := range reports {
+		y := (i + 1) * rowHeight
+		canvas.Text(10, y+18, report.Scenario, "font-family:sans-serif;font-size:12px")
+		canvas.Rect(labelWidth, y+4, barWidth, rowHeight-8, "fill:none;stroke:black")
+		canvas.Rect(labelWidth, y+4, int(float64(barWidth)*report.ZoneBalance), rowHeight-8, "fill:seagreen")
+		canvas.Text(labelWidth+4, y+18, fmt.Sprintf("%.2f", report.ZoneBalance), "font-family:sans-serif;font-size:12px;fill:white")
+
+		total := 0
+		for _, count := range report.InstancesByZone {
+			total += count
+		}
+		if total == 0 {
+			continue
+		}
+		x := labelWidth + barWidth + 20
+		for z, zone := range zones {
+			width := barWidth * report.InstancesByZone[zone] / total
+			canvas.Rect(x, y+4, width, rowHeight-8, "fill:"+colors[z%len(colors)])
+			x += width
+		}
+	}
+
+	canvas.End()
+	return nil
+}
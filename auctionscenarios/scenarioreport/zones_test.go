@@ -0,0 +1,90 @@
+package scenarioreport_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/auction/simulation/visualization"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenarioreport"
+)
+
+var _ = Describe("Zones", func() {
+	zoneOf := map[string]string{
+		"rep-lite-1": "z1",
+		"rep-lite-2": "z2",
+		"rep-lite-3": "z1",
+		"rep-lite-4": "z2",
+	}
+
+	It("lists the zones in name order", func() {
+		Ω(scenarioreport.Zones(map[string]string{"a": "z2", "b": "z1", "c": "z2"})).Should(Equal([]string{"z1", "z2"}))
+	})
+
+	It("counts a process as balanced when no zone has more than one instance more than another", func() {
+		balance, instancesByZone := scenarioreport.ZoneBalance(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": instances("a", 2),
+			"rep-lite-2": instances("a", 1),
+		}, zoneOf)
+		Ω(balance).Should(Equal(1.0))
+		Ω(instancesByZone).Should(Equal(map[string]int{"z1": 2, "z2": 1}))
+
+		balance, instancesByZone = scenarioreport.ZoneBalance(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": instances("a", 1),
+			"rep-lite-3": instances("a", 1),
+		}, zoneOf)
+		Ω(balance).Should(Equal(0.0))
+		Ω(instancesByZone).Should(Equal(map[string]int{"z1": 2}))
+	})
+
+	It("leaves out processes with a single instance", func() {
+		balance, _ := scenarioreport.ZoneBalance(map[string][]auctiontypes.SimulatedInstance{
+			"rep-lite-1": append(instances("a", 1), instances("b", 2)...),
+			"rep-lite-2": instances("a", 1),
+			"rep-lite-3": instances("b", 1),
+			"rep-lite-4": instances("c", 1),
+		}, zoneOf)
+		Ω(balance).Should(Equal(0.5))
+	})
+
+	It("draws a row for every report", func() {
+		dir, err := ioutil.TempDir("", "scenarioreport")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "report-zones.svg")
+		reports := []*scenarioreport.Report{
+			{Report: &visualization.Report{}, Scenario: "cold start", ZoneBalance: 0.5, InstancesByZone: map[string]int{"z1": 3, "z2": 1}},
+			{Report: &visualization.Report{}, Scenario: "duplicates", ZoneBalance: 1},
+		}
+		err = scenarioreport.WriteZoneReport(path, reports, []string{"z1", "z2"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		svg, err := ioutil.ReadFile(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(svg)).Should(ContainSubstring("instances per zone: z1, z2"))
+		Ω(string(svg)).Should(ContainSubstring("cold start"))
+		Ω(string(svg)).Should(ContainSubstring("duplicates"))
+	})
+
+	It("writes nothing with fewer than two zones", func() {
+		dir, err := ioutil.TempDir("", "scenarioreport")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "report-zones.svg")
+		err = scenarioreport.WriteZoneReport(path, []*scenarioreport.Report{{Report: &visualization.Report{}, Scenario: "cold start"}}, []string{"z1"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = os.Stat(path)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("fails when it can't write the report", func() {
+		err := scenarioreport.WriteZoneReport("/nonexistent/report-zones.svg", nil, []string{"z1", "z2"})
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -1,37 +1,54 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
+
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/sweep"
 )
 
+var sweepPath = flag.String("sweep", "", "a JSON or YAML sweep spec (defaults to sweeping numCells, maxConcurrent, maxBiddingPoolFraction and algorithm)")
+var dryRun = flag.Bool("dryRun", false, "print the runs without running them")
+
 func main() {
-	numCells := []int{25, 50, 100, 150, 200, 250, 300, 350, 400}
-	maxConcurrent := []int{1, 2, 5}
-	biddingPoolFraction := []float64{0.05, 0.1, 0.2}
-	algorithm := []string{"compare_to_percentile", "all_rebid"}
-
-	for _, numCell := range numCells {
-		for _, maxConc := range maxConcurrent {
-			for _, bidPool := range biddingPoolFraction {
-				for _, alg := range algorithm {
-					cmd := exec.Command(
-						"./auctionscenarios.test",
-						fmt.Sprintf("--numCells=%d", numCell),
-						fmt.Sprintf("--maxConcurrent=%d", maxConc),
-						fmt.Sprintf("--maxBiddingPoolFraction=%.2f", bidPool),
-						fmt.Sprintf("--algorithm=%s", alg),
-					)
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					t := time.Now()
-					fmt.Printf("Running %d cells, %d maxConcurrent, %.2f maxBiddingPoolFraction, %s algorithm\n", numCell, maxConc, bidPool, alg)
-					cmd.Run()
-					fmt.Printf("Done in %s\n", time.Since(t))
-				}
-			}
+	flag.Parse()
+
+	s := sweep.Default
+	if *sweepPath != "" {
+		var err error
+		s, err = sweep.Load(*sweepPath)
+		if err != nil {
+			log.Fatalln("failed to load sweep:", err)
+		}
+	}
+
+	runs := s.Combinations()
+	failed := 0
+	for i, run := range runs {
+		args := append(append([]string{}, s.Args...), run...)
+		fmt.Printf("Running %d/%d: %s\n", i+1, len(runs), strings.Join(args, " "))
+		if *dryRun {
+			continue
+		}
+
+		cmd := exec.Command(s.Test, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		t := time.Now()
+		err := cmd.Run()
+		fmt.Printf("Done in %s\n", time.Since(t))
+		if err != nil {
+			log.Printf("run %d/%d failed: %s: %s", i+1, len(runs), strings.Join(args, " "), err)
+			failed++
 		}
 	}
+
+	if failed > 0 {
+		log.Fatalf("%d of %d runs failed", failed, len(runs))
+	}
 }
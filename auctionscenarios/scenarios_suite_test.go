@@ -3,17 +3,31 @@ package main_test
 /* this is meant to be run on a Diego bosh node */
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/cloudfoundry-incubator/auction/communication/http/auction_http_client"
+	"github.com/cloudfoundry-incubator/auction/communication/nats/auction_nats_client"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/addresstable"
 	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/cellmix"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/ordering"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenarioreport"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenariospec"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/workloadsnapshot"
 	"github.com/pivotal-golang/lager"
 
 	"github.com/cloudfoundry-incubator/auction/auctionrunner"
@@ -21,6 +35,7 @@ import (
 	"github.com/cloudfoundry-incubator/auction/simulation/visualization"
 	"github.com/cloudfoundry-incubator/auction/util"
 	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/cloudfoundry/yagnats"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -29,15 +44,55 @@ import (
 )
 
 var numCells int
+var repsPerProcess int
+var addressTablePath string
 var numAuctioneers int
 var concurrentAuctionsPerAuctioneer int
 var timeout time.Duration
 var communicationMode string
+var distributorMode string
+var natsAddresses string
+var natsUsername string
+var natsPassword string
+
+var auctionTimeout time.Duration
+var partitionStrategyName string
+var auctioneerWeights string
+var partitionStrategy auctiondistributor.PartitionStrategy
+var arrivalScheduleName string
+var arrivalRate float64
+var arrivalTrace string
+var arrivalSchedule auctiondistributor.ArrivalSchedule
 
 var auctionDistributor auctiondistributor.AuctionDistributor
+var auctioneers []string
+var auctioneerGuids []string
+var natsClient yagnats.NATSClient
+var auctionContext context.Context
+var cancelAuctions context.CancelFunc
+var runsInFlight = &sync.WaitGroup{}
+
+var failOnInfrastructureFailures bool
+var seed int64
+var orderingName string
+var exportWorkloadsDir string
+var replayWorkloadsDir string
+var numEvacuatingCells int
+var faultMode string
+var numFaultyCells int
+var faultAfter time.Duration
+var repLatencyDistribution string
+var repLatency time.Duration
+var repJitter time.Duration
+var repErrorProbability float64
+var cellMixDescription string
+var expectedCellMix cellmix.Mix
+var repCapacities map[string]auctiontypes.Resources
+var zoneNames string
+var zonedRepAddresses []zonedRepAddress
 
 var svgReport *visualization.SVGReport
-var reports []*visualization.Report
+var reports []*scenarioreport.Report
 
 var client auctiontypes.SimulationRepPoolClient
 var repAddresses []auctiontypes.RepAddress
@@ -45,12 +100,43 @@ var reportName string
 
 func init() {
 	flag.IntVar(&numCells, "numCells", 100, "the number of cells")
+	flag.IntVar(&repsPerProcess, "repsPerProcess", 1, "how many reps each rep-lite process hosts (see rep-lite's -numReps); -numCells counts reps, not processes")
+	flag.StringVar(&addressTablePath, "addressTable", "", "an address table written by the launcher; its reps and auctioneers replace -numCells, -numAuctioneers and the diego-1.cf-app.com routes")
 	flag.IntVar(&numAuctioneers, "numAuctioneers", 0, "the number of auctioneers (0 means use the number of cells)")
 	flag.IntVar(&concurrentAuctionsPerAuctioneer, "maxConcurrent", 2, "the maximum number of concurrent auctions to run, per auctioneer")
 	flag.Float64Var(&(auctionrunner.DefaultStartAuctionRules.MaxBiddingPoolFraction), "maxBiddingPoolFraction", auctionrunner.DefaultStartAuctionRules.MaxBiddingPoolFraction, "the maximum number of participants in the pool")
 	flag.DurationVar(&timeout, "timeout", time.Second, "timeout when waiting for responses from remote calls")
 	flag.StringVar(&(auctionrunner.DefaultStartAuctionRules.Algorithm), "algorithm", auctionrunner.DefaultStartAuctionRules.Algorithm, "the auction algorithm to use")
 	flag.StringVar(&communicationMode, "communicationMode", "HTTP", "one of NATS or HTTP")
+	flag.StringVar(&distributorMode, "distributor", "external", "one of external (auctioneer-lite processes over HTTP), nats (auctioneer-lite processes over NATS) or in-process")
+	flag.DurationVar(&auctionTimeout, "auctionTimeout", auctiondistributor.DefaultAuctionTimeout, "how long to wait for each batch of auctions before giving up on the stragglers")
+	flag.StringVar(&partitionStrategyName, "partitionStrategy", auctiondistributor.RoundRobinPartitioning, "how auctions are split across auctioneers, one of "+strings.Join(auctiondistributor.PartitionStrategies, ", "))
+	flag.StringVar(&auctioneerWeights, "auctioneerWeights", "", "comma-separated auctioneer capacities for the weighted partition strategy, repeated across auctioneers")
+	flag.StringVar(&arrivalScheduleName, "arrivalSchedule", auctiondistributor.BurstArrivals, "when auctions arrive at their auctioneer, one of "+strings.Join(auctiondistributor.ArrivalSchedules, ", ")+" (raise -auctionTimeout for slow schedules)")
+	flag.Float64Var(&arrivalRate, "arrivalRate", 0, "auctions per second, across all auctioneers, for the constant and poisson arrival schedules")
+	flag.StringVar(&arrivalTrace, "arrivalTrace", "", "file of arrival timestamps in seconds, one per line, for the trace arrival schedule")
+	flag.IntVar(&(auctiondistributor.DefaultRetryPolicy.MaxAttempts), "maxSubmitAttempts", auctiondistributor.DefaultRetryPolicy.MaxAttempts, "how many times to try handing a batch of auctions to an auctioneer before redistributing it")
+	flag.DurationVar(&(auctiondistributor.DefaultRetryPolicy.AttemptTimeout), "submitTimeout", auctiondistributor.DefaultRetryPolicy.AttemptTimeout, "timeout for each attempt at handing a batch of auctions to an auctioneer")
+	flag.DurationVar(&(auctiondistributor.DefaultRetryPolicy.InitialBackoff), "submitBackoff", auctiondistributor.DefaultRetryPolicy.InitialBackoff, "initial backoff between attempts, doubled after every failure")
+	flag.DurationVar(&(auctiondistributor.DefaultRetryPolicy.MaxBackoff), "maxSubmitBackoff", auctiondistributor.DefaultRetryPolicy.MaxBackoff, "upper bound on the backoff between attempts")
+	flag.IntVar(&numEvacuatingCells, "numEvacuatingCells", 0, "the number of cells to drain in the evacuation scenario (0 means 5% of the cells)")
+	flag.StringVar(&faultMode, "faultMode", "none", "break reps partway through each start auction batch, one of none, kill, freeze or blackhole")
+	flag.IntVar(&numFaultyCells, "numFaultyCells", 0, "the number of reps to break when -faultMode is set (0 means 5% of the cells)")
+	flag.DurationVar(&faultAfter, "faultAfter", time.Second, "how long into a start auction batch to break the reps")
+	flag.StringVar(&repLatencyDistribution, "repLatencyDistribution", "", "latency to inject into every rep's auction calls, one of none, fixed, uniform or long-tail (empty leaves the reps as they are)")
+	flag.DurationVar(&repLatency, "repLatency", 0, "the fixed latency, or the minimum latency for uniform and long-tail")
+	flag.DurationVar(&repJitter, "repJitter", 0, "the spread of uniform latency, or the scale of the long-tail")
+	flag.Float64Var(&repErrorProbability, "repErrorProbability", 0, "the probability that a rep fails a bid, rebid/reserve or claim")
+	flag.StringVar(&cellMixDescription, "cellMix", "", "the cell-size mix the reps were started with, e.g. 0.7:100,0.3:400 (see rep-lite's -cellMix); used to check the reps' capacities")
+	flag.StringVar(&zoneNames, "zones", "", "comma-separated zones to assign round-robin to reps that were not started with rep-lite's -zone")
+	flag.Int64Var(&seed, "seed", 0, "seeds every random choice the scenarios make, so that a run's workloads can be generated again (0 picks a seed from the clock)")
+	flag.StringVar(&orderingName, "ordering", "", "the order start auctions are submitted in, one of "+strings.Join(ordering.Names, ", ")+"; overrides the scenario files' ordering (empty leaves it to the scenarios, which default to generated)")
+	flag.StringVar(&exportWorkloadsDir, "exportWorkloads", "", "a directory to write every scenario's initial distribution and auctions to, one JSON file per scenario")
+	flag.StringVar(&replayWorkloadsDir, "replayWorkloads", "", "a directory of workloads written by -exportWorkloads to run instead of generating new ones")
+	flag.BoolVar(&failOnInfrastructureFailures, "failOnInfrastructureFailures", false, "fail a scenario when an auctioneer fails or auctions are lost, rather than just reporting it")
+	flag.StringVar(&natsAddresses, "natsAddresses", "", "nats addresses, required by the nats distributor and NATS communication with the in-process distributor")
+	flag.StringVar(&natsUsername, "natsUsername", "", "nats username")
+	flag.StringVar(&natsPassword, "natsPassword", "", "nats password")
 }
 
 func TestAuction(t *testing.T) {
@@ -60,30 +146,133 @@ func TestAuction(t *testing.T) {
 
 var _ = BeforeSuite(func() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
+	Ω(scenarioSpecsErr).ShouldNot(HaveOccurred())
+	Ω(scenarioSpecs).ShouldNot(BeEmpty(), fmt.Sprintf("no scenario files in %s, run the suite from auctionscenarios or set %s", scenariospec.Dir(), scenariospec.DirEnv))
+	if replayWorkloadsDir != "" {
+		// BeforeEach seeds util.R before every scenario generates anything, so
+		// replays need the exported seed from the start
+		replayedSeed, err := workloadsnapshot.Seed(replayWorkloadsDir)
+		Ω(err).ShouldNot(HaveOccurred())
+		seed = replayedSeed
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Println("Seed:", seed)
+	err := auctiondistributor.DefaultRetryPolicy.Validate()
+	Ω(err).ShouldNot(HaveOccurred(), "-maxSubmitAttempts")
+	if orderingName != "" {
+		_, err := ordering.Apply(orderingName, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+	if exportWorkloadsDir != "" {
+		err := os.MkdirAll(exportWorkloadsDir, 0777)
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	var table addresstable.Table
+	if addressTablePath != "" {
+		var err error
+		table, err = addresstable.Load(addressTablePath)
+		Ω(err).ShouldNot(HaveOccurred())
+		numCells = len(table.Reps)
+		numAuctioneers = len(table.Auctioneers)
+	}
 
-	reportName = fmt.Sprintf("%s-%dcells-%dconc-%.2fpool", auctionrunner.DefaultStartAuctionRules.Algorithm, numCells, concurrentAuctionsPerAuctioneer, auctionrunner.DefaultStartAuctionRules.MaxBiddingPoolFraction)
+	reportName = fmt.Sprintf("%s-%s-%s-%dcells-%dconc-%.2fpool", auctionrunner.DefaultStartAuctionRules.Algorithm, distributorMode, communicationMode, numCells, concurrentAuctionsPerAuctioneer, auctionrunner.DefaultStartAuctionRules.MaxBiddingPoolFraction)
 	if numAuctioneers == 0 {
 		numAuctioneers = numCells
 	}
 
+	auctionContext, cancelAuctions = context.WithCancel(context.Background())
+
 	startReport()
 
-	auctioneers := []string{}
-	repAddresses = []auctiontypes.RepAddress{}
-	for i := 1; i <= numCells; i++ {
-		repAddresses = append(repAddresses, auctiontypes.RepAddress{
-			RepGuid: fmt.Sprintf("rep-lite-%d", i),
-			Address: fmt.Sprintf("http://rep-lite-%d.diego-1.cf-app.com", i),
-		})
-	}
-	for i := 1; i <= numAuctioneers; i++ {
-		auctioneers = append(auctioneers, fmt.Sprintf("auctioneer-lite-%d.diego-1.cf-app.com", i))
+	auctioneers = []string{}
+	auctioneerGuids = []string{}
+	if addressTablePath != "" {
+		repAddresses = table.Reps
+		auctioneers = table.AuctioneerHosts()
+		auctioneerGuids = table.AuctioneerGuids()
+	} else {
+		repAddresses = buildRepAddresses(numCells, repsPerProcess)
+		for i := 1; i <= numAuctioneers; i++ {
+			auctioneers = append(auctioneers, fmt.Sprintf("auctioneer-lite-%d.diego-1.cf-app.com", i))
+			auctioneerGuids = append(auctioneerGuids, fmt.Sprintf("auctioneer-lite-%d", i))
+		}
 	}
 	client = auction_http_client.New(http.DefaultClient, lager.NewLogger("client"))
+	configureRepLatency()
+	fetchRepCapacities()
+	fetchRepZones()
+
+	weights := []float64{}
+	if auctioneerWeights != "" {
+		for _, weight := range strings.Split(auctioneerWeights, ",") {
+			w, err := strconv.ParseFloat(weight, 64)
+			Ω(err).ShouldNot(HaveOccurred())
+			weights = append(weights, w)
+		}
+	}
+	partitionStrategy, err = auctiondistributor.NewPartitionStrategy(partitionStrategyName, weights)
+	Ω(err).ShouldNot(HaveOccurred())
+	arrivalSchedule, err = auctiondistributor.NewArrivalSchedule(arrivalScheduleName, arrivalRate, arrivalTrace)
+	Ω(err).ShouldNot(HaveOccurred())
 
-	auctionDistributor = auctiondistributor.NewExternalAuctionDistributor(auctioneers, concurrentAuctionsPerAuctioneer, communicationMode)
+	auctionDistributor = newAuctionDistributor(arrivalSchedule)
 })
 
+// newAuctionDistributor builds the -distributor, so that scenarios can run
+// with an arrival schedule of their own.
+func newAuctionDistributor(arrivalSchedule auctiondistributor.ArrivalSchedule) auctiondistributor.AuctionDistributor {
+	switch distributorMode {
+	case "external":
+		return auctiondistributor.NewExternalAuctionDistributor(auctioneers, concurrentAuctionsPerAuctioneer, communicationMode, auctiondistributor.DefaultRetryPolicy, partitionStrategy, arrivalSchedule)
+	case "nats":
+		return auctiondistributor.NewNATSAuctionDistributor(connectToNATS(), auctioneerGuids, concurrentAuctionsPerAuctioneer, communicationMode, partitionStrategy, arrivalSchedule)
+	case "in-process":
+		var repClient auctiontypes.RepPoolClient
+		if communicationMode == "NATS" {
+			var err error
+			repClient, err = auction_nats_client.New(connectToNATS(), timeout, lager.NewLogger("in-process-auctioneer"))
+			Ω(err).ShouldNot(HaveOccurred())
+		} else {
+			repClient = auction_http_client.New(&http.Client{
+				Timeout: timeout,
+			}, lager.NewLogger("in-process-auctioneer"))
+		}
+		return auctiondistributor.NewInProcessAuctionDistributor(repClient, concurrentAuctionsPerAuctioneer, partitionStrategy, arrivalSchedule)
+	default:
+		Fail("unknown distributor: " + distributorMode)
+	}
+	return nil
+}
+
+func connectToNATS() yagnats.NATSClient {
+	if natsClient != nil {
+		return natsClient
+	}
+	Ω(natsAddresses).ShouldNot(BeEmpty(), "-natsAddresses is required")
+
+	natsMembers := []string{}
+	for _, addr := range strings.Split(natsAddresses, ",") {
+		uri := url.URL{
+			Scheme: "nats",
+			Host:   addr,
+		}
+		if natsUsername != "" {
+			uri.User = url.UserPassword(natsUsername, natsPassword)
+		}
+		natsMembers = append(natsMembers, uri.String())
+	}
+
+	var err error
+	natsClient, err = yagnats.Connect(natsMembers)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	return natsClient
+}
+
 var _ = BeforeEach(func() {
 	workers := workpool.NewWorkPool(50)
 	wg := &sync.WaitGroup{}
@@ -100,14 +289,350 @@ var _ = BeforeEach(func() {
 	workers.Stop()
 
 	util.ResetGuids()
+	util.R.Seed(seed)
 })
 
+// Ginkgo runs AfterSuite on Ctrl-C too, and exits as soon as it returns, so
+// this is where a run in flight is cancelled: the distributors cancel their
+// jobs before returning, and startRun's done waits for that.
 var _ = AfterSuite(func() {
-	finishReport()
+	if cancelAuctions != nil {
+		cancelAuctions()
+		waitForRuns(time.Minute)
+	}
+	// there is no report to finish if BeforeSuite failed before starting it
+	if svgReport != nil {
+		finishReport()
+	}
 })
 
+// startRun bounds a run by -auctionTimeout and by the suite; call done, as
+// often as you like, once the distributor has returned.
+func startRun() (context.Context, func()) {
+	runsInFlight.Add(1)
+	ctx, cancel := context.WithTimeout(auctionContext, auctionTimeout)
+	once := &sync.Once{}
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+			runsInFlight.Done()
+		})
+	}
+}
+
+func waitForRuns(timeout time.Duration) {
+	finished := make(chan struct{})
+	go func() {
+		runsInFlight.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		fmt.Println("Gave up waiting for the runs in flight to cancel their jobs")
+	}
+}
+
+// injectFaults breaks numFaultyCells randomly chosen reps once faultAfter has
+// passed, unless ctx is done first.  Call the returned function to clear the
+// faults again; BeforeEach's Reset clears them too.
+func injectFaults(ctx context.Context) ([]auctiontypes.RepAddress, func()) {
+	if faultMode == "none" {
+		return []auctiontypes.RepAddress{}, func() {}
+	}
+
+	n := numFaultyCells
+	if n == 0 {
+		n = int(math.Ceil(float64(len(repAddresses)) * 0.05))
+	}
+	if n > len(repAddresses) {
+		n = len(repAddresses)
+	}
+	faultyRepAddresses := []auctiontypes.RepAddress{}
+	for _, index := range util.R.Perm(len(repAddresses))[:n] {
+		faultyRepAddresses = append(faultyRepAddresses, repAddresses[index])
+	}
+
+	injected := make(chan struct{})
+	go func() {
+		defer close(injected)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(faultAfter):
+		}
+		fmt.Printf("Injecting %s into %d reps\n", faultMode, len(faultyRepAddresses))
+		setFaults(faultyRepAddresses, faultMode)
+	}()
+
+	return faultyRepAddresses, func() {
+		<-injected
+		setFaults(faultyRepAddresses, "none")
+	}
+}
+
+func setFaults(faultyRepAddresses []auctiontypes.RepAddress, mode string) {
+	postToReps(faultyRepAddresses, "/fault?mode="+mode)
+}
+
+// configureRepLatency applies the -repLatency* flags to every rep through
+// rep-lite's /latency route.
+func configureRepLatency() {
+	if repLatencyDistribution == "" {
+		return
+	}
+
+	query := url.Values{}
+	query.Set("distribution", repLatencyDistribution)
+	query.Set("latency", repLatency.String())
+	query.Set("jitter", repJitter.String())
+	query.Set("errorProbability", strconv.FormatFloat(repErrorProbability, 'f', -1, 64))
+	postToReps(repAddresses, "/latency?"+query.Encode())
+}
+
+func repLatencyName() string {
+	if repLatencyDistribution == "" {
+		return "unchanged"
+	}
+	return fmt.Sprintf("%s-%s-%s-%g", repLatencyDistribution, repLatency, repJitter, repErrorProbability)
+}
+
+func postToReps(addresses []auctiontypes.RepAddress, path string) {
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+
+	workers := workpool.NewWorkPool(50)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(addresses))
+	for _, repAddress := range addresses {
+		repAddress := repAddress
+		workers.Submit(func() {
+			defer wg.Done()
+			res, err := httpClient.Post(repAddress.Address+path, "text/plain", nil)
+			if err != nil {
+				fmt.Println("Failed to post", path, "to", repAddress.RepGuid, err.Error())
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				fmt.Println("Got unexpected status code when posting", path, "to", repAddress.RepGuid, res.StatusCode)
+			}
+		})
+	}
+	wg.Wait()
+	workers.Stop()
+}
+
+func fetchRepCapacities() {
+	if cellMixDescription != "" {
+		var err error
+		expectedCellMix, err = cellmix.Parse(cellMixDescription)
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	lock := &sync.Mutex{}
+	repCapacities = map[string]auctiontypes.Resources{}
+	workers := workpool.NewWorkPool(50)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(repAddresses))
+	for _, repAddress := range repAddresses {
+		repAddress := repAddress
+		workers.Submit(func() {
+			defer wg.Done()
+			resources := client.TotalResources(repAddress)
+			lock.Lock()
+			repCapacities[repAddress.RepGuid] = resources
+			lock.Unlock()
+		})
+	}
+	wg.Wait()
+	workers.Stop()
+
+	if expectedCellMix != nil {
+		mismatched := []string{}
+		for repGuid, resources := range repCapacities {
+			index, err := cellmix.CellIndex(repGuid, repsPerProcess)
+			Ω(err).ShouldNot(HaveOccurred())
+			if resources != expectedCellMix.ResourcesFor(index) {
+				mismatched = append(mismatched, repGuid)
+			}
+		}
+		sort.Strings(mismatched)
+		Ω(mismatched).Should(BeEmpty(), fmt.Sprintf("reps do not match -cellMix=%s, were they started with the same mix?", expectedCellMix))
+	}
+}
+
+func cellMixName() string {
+	if expectedCellMix == nil {
+		return "uniform"
+	}
+	return strings.Replace(expectedCellMix.String(), ",", " ", -1)
+}
+
+// buildRepAddresses addresses numCells reps.  When every rep-lite process
+// hosts more than one rep, rep j of process i is rep-lite-<i>-<j> and is
+// served under that guid on the process's route.
+func buildRepAddresses(numCells int, repsPerProcess int) []auctiontypes.RepAddress {
+	addresses := []auctiontypes.RepAddress{}
+	if repsPerProcess <= 1 {
+		for i := 1; i <= numCells; i++ {
+			addresses = append(addresses, auctiontypes.RepAddress{
+				RepGuid: fmt.Sprintf("rep-lite-%d", i),
+				Address: fmt.Sprintf("http://rep-lite-%d.diego-1.cf-app.com", i),
+			})
+		}
+		return addresses
+	}
+
+	for n := 0; n < numCells; n++ {
+		process, rep := n/repsPerProcess+1, n%repsPerProcess+1
+		guid := fmt.Sprintf("rep-lite-%d-%d", process, rep)
+		addresses = append(addresses, auctiontypes.RepAddress{
+			RepGuid: guid,
+			Address: fmt.Sprintf("http://rep-lite-%d.diego-1.cf-app.com/%s", process, guid),
+		})
+	}
+	return addresses
+}
+
+type zonedRepAddress struct {
+	auctiontypes.RepAddress
+	Zone string
+}
+
+// fetchRepZones asks every rep for its zone.  Reps that don't know theirs are
+// assigned one of -zones round-robin.
+func fetchRepZones() {
+	fallbackZones := []string{}
+	if zoneNames != "" {
+		fallbackZones = strings.Split(zoneNames, ",")
+	}
+
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+
+	zonedRepAddresses = make([]zonedRepAddress, len(repAddresses))
+	workers := workpool.NewWorkPool(50)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(repAddresses))
+	for i, repAddress := range repAddresses {
+		i := i
+		repAddress := repAddress
+		workers.Submit(func() {
+			defer wg.Done()
+			zonedRepAddresses[i] = zonedRepAddress{RepAddress: repAddress}
+			res, err := httpClient.Get(repAddress.Address + "/zone")
+			if err == nil {
+				defer res.Body.Close()
+				zone, err := ioutil.ReadAll(res.Body)
+				if err == nil && res.StatusCode == http.StatusOK {
+					zonedRepAddresses[i].Zone = strings.TrimSpace(string(zone))
+				}
+			}
+			if zonedRepAddresses[i].Zone == "" && len(fallbackZones) > 0 {
+				zonedRepAddresses[i].Zone = fallbackZones[i%len(fallbackZones)]
+			}
+		})
+	}
+	wg.Wait()
+	workers.Stop()
+}
+
+func repZones() map[string]string {
+	zones := map[string]string{}
+	for _, repAddress := range zonedRepAddresses {
+		zones[repAddress.RepGuid] = repAddress.Zone
+	}
+	return zones
+}
+
+func exportWorkload(snapshot workloadsnapshot.Snapshot) {
+	if exportWorkloadsDir == "" {
+		return
+	}
+
+	err := workloadsnapshot.Export(exportWorkloadsDir, snapshot)
+	Ω(err).ShouldNot(HaveOccurred())
+}
+
+func loadReplayedWorkload(scenario string) (workloadsnapshot.Snapshot, bool) {
+	if replayWorkloadsDir == "" {
+		return workloadsnapshot.Snapshot{}, false
+	}
+
+	snapshot, err := workloadsnapshot.Load(replayWorkloadsDir, scenario)
+	Ω(err).ShouldNot(HaveOccurred(), "no workload to replay for "+scenario)
+	fmt.Printf("Replaying %s (seed %d) from %s\n", scenario, snapshot.Seed, workloadsnapshot.Path(replayWorkloadsDir, scenario))
+	return snapshot, true
+}
+
+// the scenario files in scenarios/ and the built-in scenarios take the first
+// two rows of the SVG report; scenario files without a card of their own are
+// laid out below them
+const builtInReportRows = 2
+
+// scenario files are loaded when the suite is defined, before flags are
+// parsed, so their directory comes from the environment
+var scenarioSpecs, scenarioSpecsErr = scenariospec.Load(scenariospec.Dir(), builtInReportRows)
+
+// startAuctionOrdering picks the ordering for a scenario: -ordering if given,
+// then the scenario's own, then generated.
+func startAuctionOrdering(scenarioOrdering string) string {
+	if orderingName != "" {
+		return orderingName
+	}
+	if scenarioOrdering != "" {
+		return scenarioOrdering
+	}
+	return ordering.Generated
+}
+
+func reportRows() int {
+	rows := builtInReportRows
+	for _, spec := range scenarioSpecs {
+		if spec.Card[1]+1 > rows {
+			rows = spec.Card[1] + 1
+		}
+	}
+	return rows
+}
+
+func printStopAuctionReport(report *scenarioreport.Report, nStopAuctions int) {
+	nFinished, communication, _ := report.StopAuctionStats()
+	fmt.Printf("%d/%d stop auctions finished in %s with %d communications\n", nFinished, nStopAuctions, report.AuctionDuration, communication)
+	fmt.Printf("Stopped %d of %d duplicate instances, %d remain\n", report.NDuplicatesStopped(), report.NDuplicatesBefore, report.NDuplicatesAfter)
+
+	keptByRep := report.KeptByRep()
+	repGuids := []string{}
+	for repGuid := range keptByRep {
+		repGuids = append(repGuids, repGuid)
+	}
+	sort.Strings(repGuids)
+	fmt.Printf("%d reps kept instances:\n", len(repGuids))
+	for _, repGuid := range repGuids {
+		fmt.Printf("  %s: %d\n", repGuid, keptByRep[repGuid])
+	}
+}
+
+func printOutcome(outcome auctiondistributor.RunOutcome) {
+	if !outcome.HasInfrastructureFailures() {
+		return
+	}
+
+	fmt.Printf("Infrastructure failures: %d auctioneers failed, %d auctions lost, %d possibly run twice\n", outcome.NFailedAuctioneers(), outcome.NLostAuctions(), outcome.NPossibleDuplicates())
+	for _, auctioneer := range outcome.Auctioneers {
+		fmt.Printf("  %s: %d/%d requests unacknowledged, %d possibly duplicated\n", auctioneer.Auctioneer, auctioneer.NUnacknowledged, auctioneer.NRequests, auctioneer.NPossiblyDuplicated)
+		for _, failure := range auctioneer.Failures {
+			fmt.Printf("    %s (status %d) x%d: %s\n", failure.Class, failure.StatusCode, failure.Count, failure.LastMessage)
+		}
+	}
+}
+
 func startReport() {
-	svgReport = visualization.StartSVGReport("./"+reportName+".svg", 3, 1, numCells)
+	svgReport = visualization.StartSVGReport("./"+reportName+".svg", 3, reportRows(), numCells)
 	svgReport.DrawHeader("Diego Scenario", auctionrunner.DefaultStartAuctionRules, concurrentAuctionsPerAuctioneer)
 }
 
@@ -118,32 +643,29 @@ func finishReport() {
 		exec.Command("rsvg-convert", "-h", "2000", "--background-color=#fff", "./"+reportName+".svg", "-o", "./"+reportName+".png").Run()
 		// exec.Command("open", "./"+reportName+".png").Run()
 	}
+	err = scenarioreport.WriteZoneReport("./"+reportName+"-zones.svg", reports, scenarioreport.Zones(repZones()))
+	if err != nil {
+		fmt.Println("Failed to write zone report", err.Error())
+	}
+	err = scenarioreport.WriteStopReport("./"+reportName+"-stops.svg", reports)
+	if err != nil {
+		fmt.Println("Failed to write stop auction report", err.Error())
+	}
 	data, err := json.Marshal(reports)
 	Ω(err).ShouldNot(HaveOccurred())
 	ioutil.WriteFile("./"+reportName+".json", data, 0777)
 
-	summaryBytes, err := ioutil.ReadFile("./summary.csv")
-	summary := string(summaryBytes)
-	if err != nil {
-		summary = "numCells,numAuctioneers,concurrentAuctionsPerAuctioneer,maxBiddingPoolFraction,algorithm,scenario,# auctions,communication,waitTime,biddingTime,distributionScore,nMissing\n"
-	}
-
-	for i, scenario := range []string{"10% start", "cold start", "rolling deploy"} {
-		summary += fmt.Sprintf("%d,%d,%d,%.2f,%s,%s,%d,%d,%.2f,%.2f,%.4f,%d\n",
-			numCells,
-			numAuctioneers,
-			concurrentAuctionsPerAuctioneer,
-			auctionrunner.DefaultStartAuctionRules.MaxBiddingPoolFraction,
-			auctionrunner.DefaultStartAuctionRules.Algorithm,
-			scenario,
-			reports[i].NAuctions(),
-			int64(reports[i].CommStats().Total),
-			reports[i].WaitTimeStats().Max,
-			reports[i].BiddingTimeStats().Max,
-			reports[i].DistributionScore(),
-			reports[i].NMissingInstances(),
-		)
-	}
-
-	ioutil.WriteFile("./summary.csv", []byte(summary), 0666)
+	err = scenarioreport.AppendToSummary("./summary.csv", scenarioreport.Run{
+		NumCells:                        numCells,
+		NumAuctioneers:                  numAuctioneers,
+		ConcurrentAuctionsPerAuctioneer: concurrentAuctionsPerAuctioneer,
+		Rules:                           auctionrunner.DefaultStartAuctionRules,
+		PartitionStrategy:               partitionStrategy.Name(),
+		RepLatency:                      repLatencyName(),
+		CellMix:                         cellMixName(),
+		NZones:                          len(scenarioreport.Zones(repZones())),
+		Distributor:                     distributorMode,
+		CommunicationMode:               communicationMode,
+	}, reports)
+	Ω(err).ShouldNot(HaveOccurred())
 }
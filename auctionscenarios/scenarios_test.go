@@ -1,6 +1,7 @@
 package main_test
 
 import (
+	"fmt"
 	"math"
 	"sync"
 	"time"
@@ -13,6 +14,11 @@ import (
 	"github.com/cloudfoundry/gunk/workpool"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/ordering"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenarioreport"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenariospec"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/workloadsnapshot"
 )
 
 var _ = Ω
@@ -38,12 +44,12 @@ var _ = Describe("Two Scenarios", func() {
 		return instances
 	}
 
-	newLRPStartAuction := func(processGuid string, memoryMB int) models.LRPStartAuction {
+	newLRPStartAuction := func(processGuid string, memoryMB int, diskMB int) models.LRPStartAuction {
 		return models.LRPStartAuction{
 			DesiredLRP: models.DesiredLRP{
 				ProcessGuid: processGuid,
 				MemoryMB:    memoryMB,
-				DiskMB:      1,
+				DiskMB:      diskMB,
 			},
 
 			InstanceGuid: util.NewGuid("INS"),
@@ -51,36 +57,67 @@ var _ = Describe("Two Scenarios", func() {
 		}
 	}
 
-	generateUniqueLRPStartAuctions := func(numInstances int, memoryMB int) []models.LRPStartAuction {
-		instances := []models.LRPStartAuction{}
-		for i := 0; i < numInstances; i++ {
-			instances = append(instances, newLRPStartAuction(util.NewGrayscaleGuid("BBB"), memoryMB))
+	processGuidFor := func(spec scenariospec.Instances, prefix string) string {
+		if len(spec.Colors) == 0 {
+			return util.NewGrayscaleGuid(prefix)
+		}
+		return spec.Colors[util.R.Intn(len(spec.Colors))]
+	}
+
+	diskMBFor := func(spec scenariospec.Instances) int {
+		if spec.DiskMB == 0 {
+			return 1
+		}
+		return spec.DiskMB
+	}
+
+	countFor := func(spec scenariospec.Instances) int {
+		if spec.MaxCount > spec.Count {
+			return util.RandomIntIn(spec.Count, spec.MaxCount)
+		}
+		return spec.Count
+	}
+
+	generateSimulatedInstances := func(spec scenariospec.Instances) []auctiontypes.SimulatedInstance {
+		instances := []auctiontypes.SimulatedInstance{}
+		for i, n := 0, countFor(spec); i < n; i++ {
+			instance := newSimulatedInstance(processGuidFor(spec, "AAA"), 0, spec.MemoryMB)
+			instance.DiskMB = diskMBFor(spec)
+			instances = append(instances, instance)
 		}
 		return instances
 	}
 
-	generateLRPStartAuctionsWithRandomColor := func(numInstances int, memoryMB int, colors []string) []models.LRPStartAuction {
+	generateLRPStartAuctions := func(spec scenariospec.Instances, numEmptyCells int) []models.LRPStartAuction {
 		instances := []models.LRPStartAuction{}
-		for i := 0; i < numInstances; i++ {
-			color := colors[util.R.Intn(len(colors))]
-			instances = append(instances, newLRPStartAuction(color, memoryMB))
+		n := countFor(spec) + int(spec.PerCell*float64(numCells)) + int(spec.PerEmptyCell*float64(numEmptyCells))
+		for i := 0; i < n; i++ {
+			instances = append(instances, newLRPStartAuction(processGuidFor(spec, "BBB"), spec.MemoryMB, diskMBFor(spec)))
 		}
 		return instances
 	}
 
-	runStartAuction := func(startAuctions []models.LRPStartAuction, i int, j int) {
-		t := time.Now()
-		results := auctionDistributor.HoldStartAuctions(numAuctioneers, startAuctions, repAddresses, auctionrunner.DefaultStartAuctionRules)
-		duration := time.Since(t)
-		report := &visualization.Report{
-			RepAddresses:    repAddresses,
-			AuctionResults:  results,
-			InstancesByRep:  visualization.FetchAndSortInstances(client, repAddresses),
-			AuctionDuration: duration,
+	generateUniqueLRPStartAuctions := func(numInstances int, memoryMB int) []models.LRPStartAuction {
+		return generateLRPStartAuctions(scenariospec.Instances{Count: numInstances, MemoryMB: memoryMB}, 0)
+	}
+
+	duplicateInstances := func(numProcesses int, numCopies int) []models.LRPStopAuction {
+		if numCopies > numCells {
+			numCopies = numCells
 		}
-		visualization.PrintReport(client, len(startAuctions), results, repAddresses, duration, auctionrunner.DefaultStartAuctionRules)
-		svgReport.DrawReportCard(i, j, report)
-		reports = append(reports, report)
+
+		stopAuctions := []models.LRPStopAuction{}
+		for k := 0; k < numProcesses; k++ {
+			processGuid := util.NewGrayscaleGuid("CCC")
+			for _, j := range util.R.Perm(numCells)[:numCopies] {
+				initialDistributions[j] = append(initialDistributions[j], newSimulatedInstance(processGuid, 0, 1))
+			}
+			stopAuctions = append(stopAuctions, models.LRPStopAuction{
+				ProcessGuid: processGuid,
+				Index:       0,
+			})
+		}
+		return stopAuctions
 	}
 
 	setInitialDistribution := func(initialDistribution map[int][]auctiontypes.SimulatedInstance) {
@@ -99,6 +136,317 @@ var _ = Describe("Two Scenarios", func() {
 		workers.Stop()
 	}
 
+	orderWorkload := func(name string, startAuctions []models.LRPStartAuction) []models.LRPStartAuction {
+		ordered, err := ordering.Apply(name, startAuctions)
+		Ω(err).ShouldNot(HaveOccurred())
+		return ordered
+	}
+
+	// prepareWorkload puts the start auctions in the snapshot's ordering and
+	// exports the scenario's inputs, or, when replaying, swaps them for the
+	// exported ones, in the order they were submitted, and puts the exported
+	// instances on the reps.  An -ordering other than the exported one
+	// reorders the replayed auctions.
+	prepareWorkload := func(snapshot workloadsnapshot.Snapshot) workloadsnapshot.Snapshot {
+		replayed, ok := loadReplayedWorkload(snapshot.Scenario)
+		if ok {
+			initialDistributions = map[int][]auctiontypes.SimulatedInstance{}
+			for index, repAddress := range repAddresses {
+				initialDistributions[index] = replayed.InitialDistribution[repAddress.RepGuid]
+			}
+			setInitialDistribution(initialDistributions)
+
+			if orderingName != "" && orderingName != replayed.Ordering {
+				replayed.Ordering = orderingName
+				replayed.StartAuctions = orderWorkload(replayed.Ordering, replayed.StartAuctions)
+			}
+			return replayed
+		}
+
+		snapshot.Seed = seed
+		snapshot.StartAuctions = orderWorkload(snapshot.Ordering, snapshot.StartAuctions)
+		snapshot.InitialDistribution = map[string][]auctiontypes.SimulatedInstance{}
+		for index, instances := range initialDistributions {
+			snapshot.InitialDistribution[repAddresses[index].RepGuid] = instances
+		}
+		exportWorkload(snapshot)
+		return snapshot
+	}
+
+	recordReport := func(report *scenarioreport.Report) {
+		printOutcome(report.Outcome)
+		if report.ArrivalSchedule == "" {
+			report.ArrivalSchedule = arrivalSchedule.Name()
+		}
+		report.NormalizedDistributionScore = scenarioreport.NormalizeByCapacity(report.Report, repCapacities).DistributionScore()
+		report.ZoneBalance, report.InstancesByZone = scenarioreport.ZoneBalance(report.InstancesByRep, repZones())
+		reports = append(reports, report)
+
+		if failOnInfrastructureFailures {
+			Ω(report.Outcome.HasInfrastructureFailures()).Should(BeFalse(), "the auction infrastructure failed, see the outcome above")
+		}
+	}
+
+	// stop auctions place nothing, so only scenarios that start instances get
+	// a report card; the stop auctions are charted in <reportName>-stops.svg
+	recordReportCard := func(report *scenarioreport.Report, i int, j int) {
+		svgReport.DrawReportCard(i, j, report.Report)
+		recordReport(report)
+	}
+
+	runStartAuction := func(scenario string, startAuctions []models.LRPStartAuction, scenarioOrdering string, schedule auctiondistributor.ArrivalSchedule, i int, j int) *scenarioreport.Report {
+		snapshot := prepareWorkload(workloadsnapshot.Snapshot{
+			Scenario:      scenario,
+			Ordering:      scenarioOrdering,
+			StartAuctions: startAuctions,
+		})
+		startAuctions = snapshot.StartAuctions
+
+		distributor := auctionDistributor
+		if schedule != nil {
+			distributor = newAuctionDistributor(schedule)
+		} else {
+			schedule = arrivalSchedule
+		}
+
+		ctx, done := startRun()
+		defer done()
+
+		faultyRepAddresses, clearFaults := injectFaults(ctx)
+
+		t := time.Now()
+		results, outcome := distributor.HoldStartAuctionsWithContext(ctx, numAuctioneers, startAuctions, repAddresses, auctionrunner.DefaultStartAuctionRules)
+		duration := time.Since(t)
+		report := &visualization.Report{
+			RepAddresses:    repAddresses,
+			AuctionResults:  results,
+			InstancesByRep:  visualization.FetchAndSortInstances(client, repAddresses),
+			AuctionDuration: duration,
+		}
+		done()
+		clearFaults()
+
+		visualization.PrintReport(client, len(startAuctions), results, repAddresses, duration, auctionrunner.DefaultStartAuctionRules)
+		recorded := &scenarioreport.Report{
+			Report:            report,
+			Scenario:          scenario,
+			Kind:              scenarioreport.StartAuctionKind,
+			Ordering:          snapshot.Ordering,
+			Seed:              snapshot.Seed,
+			ArrivalSchedule:   schedule.Name(),
+			NUnplacedAuctions: scenarioreport.CountUnplacedStartAuctions(results, outcome),
+			FaultMode:         faultMode,
+			NFaultyCells:      len(faultyRepAddresses),
+			Outcome:           outcome,
+		}
+		recordReportCard(recorded, i, j)
+		return recorded
+	}
+
+	runStopAuction := func(scenario string, stopAuctions []models.LRPStopAuction) {
+		snapshot := prepareWorkload(workloadsnapshot.Snapshot{
+			Scenario:     scenario,
+			StopAuctions: stopAuctions,
+		})
+		stopAuctions = snapshot.StopAuctions
+
+		ctx, done := startRun()
+		defer done()
+
+		nDuplicatesBefore := scenarioreport.CountDuplicateInstances(visualization.FetchAndSortInstances(client, repAddresses))
+
+		t := time.Now()
+		results, outcome := auctionDistributor.HoldStopAuctionsWithContext(ctx, numAuctioneers, stopAuctions, repAddresses)
+		duration := time.Since(t)
+		report := &scenarioreport.Report{
+			Report: &visualization.Report{
+				RepAddresses:    repAddresses,
+				InstancesByRep:  visualization.FetchAndSortInstances(client, repAddresses),
+				AuctionDuration: duration,
+			},
+			Scenario:           scenario,
+			Kind:               scenarioreport.StopAuctionKind,
+			Seed:               snapshot.Seed,
+			StopAuctionResults: results,
+			NDuplicatesBefore:  nDuplicatesBefore,
+			Outcome:            outcome,
+		}
+		report.NDuplicatesAfter = scenarioreport.CountDuplicateInstances(report.InstancesByRep)
+		printStopAuctionReport(report, len(stopAuctions))
+		recordReport(report)
+	}
+
+	runWorkload := func(scenario string, startAuctions []models.LRPStartAuction, stopAuctions []models.LRPStopAuction, i int, j int) {
+		snapshot := prepareWorkload(workloadsnapshot.Snapshot{
+			Scenario:      scenario,
+			Ordering:      startAuctionOrdering(""),
+			StartAuctions: startAuctions,
+			StopAuctions:  stopAuctions,
+		})
+		workload := auctiondistributor.InterleaveWorkload(snapshot.StartAuctions, snapshot.StopAuctions)
+
+		ctx, done := startRun()
+		defer done()
+
+		nDuplicatesBefore := scenarioreport.CountDuplicateInstances(visualization.FetchAndSortInstances(client, repAddresses))
+
+		t := time.Now()
+		results, outcome := auctionDistributor.HoldWorkloadWithContext(ctx, numAuctioneers, workload, repAddresses, auctionrunner.DefaultStartAuctionRules)
+		duration := time.Since(t)
+		report := &scenarioreport.Report{
+			Report: &visualization.Report{
+				RepAddresses:    repAddresses,
+				AuctionResults:  results.StartAuctionResults,
+				InstancesByRep:  visualization.FetchAndSortInstances(client, repAddresses),
+				AuctionDuration: duration,
+			},
+			Scenario:           scenario,
+			Kind:               scenarioreport.MixedWorkloadKind,
+			Ordering:           snapshot.Ordering,
+			Seed:               snapshot.Seed,
+			StopAuctionResults: results.StopAuctionResults,
+			NDuplicatesBefore:  nDuplicatesBefore,
+			NUnplacedAuctions:  scenarioreport.CountUnplacedStartAuctions(results.StartAuctionResults, outcome),
+			Outcome:            outcome,
+		}
+		report.NDuplicatesAfter = scenarioreport.CountDuplicateInstances(report.InstancesByRep)
+		visualization.PrintReport(client, len(workload.StartAuctions()), results.StartAuctionResults, repAddresses, duration, auctionrunner.DefaultStartAuctionRules)
+		printStopAuctionReport(report, len(workload.StopAuctions()))
+		recordReportCard(report, i, j)
+	}
+
+	runEvacuation := func(scenario string, numEvacuating int, i int, j int) {
+		if numEvacuating >= numCells {
+			numEvacuating = numCells - 1
+		}
+		evacuatingIndices := map[int]bool{}
+		for _, index := range util.R.Perm(numCells)[:numEvacuating] {
+			evacuatingIndices[index] = true
+		}
+
+		evacuatedReps := []string{}
+		startAuctions := []models.LRPStartAuction{}
+		for index, repAddress := range repAddresses {
+			if !evacuatingIndices[index] {
+				continue
+			}
+			evacuatedReps = append(evacuatedReps, repAddress.RepGuid)
+			for _, instance := range initialDistributions[index] {
+				startAuctions = append(startAuctions, models.LRPStartAuction{
+					DesiredLRP: models.DesiredLRP{
+						ProcessGuid: instance.ProcessGuid,
+						MemoryMB:    instance.MemoryMB,
+						DiskMB:      instance.DiskMB,
+					},
+					InstanceGuid: util.NewGuid("INS"),
+					Index:        instance.Index,
+				})
+			}
+		}
+
+		snapshot := prepareWorkload(workloadsnapshot.Snapshot{
+			Scenario:      scenario,
+			Ordering:      startAuctionOrdering(""),
+			StartAuctions: startAuctions,
+			EvacuatedReps: evacuatedReps,
+		})
+		startAuctions = snapshot.StartAuctions
+		numEvacuating = len(snapshot.EvacuatedReps)
+
+		evacuating := map[string]bool{}
+		for _, repGuid := range snapshot.EvacuatedReps {
+			evacuating[repGuid] = true
+		}
+		remainingRepAddresses := []auctiontypes.RepAddress{}
+		evacuatingRepAddresses := []auctiontypes.RepAddress{}
+		for _, repAddress := range repAddresses {
+			if evacuating[repAddress.RepGuid] {
+				evacuatingRepAddresses = append(evacuatingRepAddresses, repAddress)
+			} else {
+				remainingRepAddresses = append(remainingRepAddresses, repAddress)
+			}
+		}
+
+		ctx, done := startRun()
+		defer done()
+
+		t := time.Now()
+		results, outcome := auctionDistributor.HoldStartAuctionsWithContext(ctx, numAuctioneers, startAuctions, remainingRepAddresses, auctionrunner.DefaultStartAuctionRules)
+		duration := time.Since(t)
+
+		for _, repAddress := range evacuatingRepAddresses {
+			client.SetSimulatedInstances(repAddress, []auctiontypes.SimulatedInstance{})
+		}
+
+		report := &scenarioreport.Report{
+			Report: &visualization.Report{
+				RepAddresses:    remainingRepAddresses,
+				AuctionResults:  results,
+				InstancesByRep:  visualization.FetchAndSortInstances(client, remainingRepAddresses),
+				AuctionDuration: duration,
+			},
+			Scenario:          scenario,
+			Kind:              scenarioreport.StartAuctionKind,
+			Ordering:          snapshot.Ordering,
+			Seed:              snapshot.Seed,
+			NEvacuatedCells:   numEvacuating,
+			NUnplacedAuctions: scenarioreport.CountUnplacedStartAuctions(results, outcome),
+			Outcome:           outcome,
+		}
+		visualization.PrintReport(client, len(startAuctions), results, remainingRepAddresses, duration, auctionrunner.DefaultStartAuctionRules)
+		fmt.Printf("Evacuated %d cells in %s, %d of %d instances could not be placed\n", numEvacuating, duration, report.NUnplacedAuctions, len(startAuctions))
+		recordReportCard(report, i, j)
+	}
+
+	runScenarioSpec := func(spec scenariospec.Spec) {
+		cell := 0
+		for _, group := range spec.InitialDistribution {
+			end := cell + int(math.Floor(group.CellFraction*float64(numCells)+1e-9))
+			for ; cell < end && cell < numCells; cell++ {
+				for _, instances := range group.Instances {
+					initialDistributions[cell] = append(initialDistributions[cell], generateSimulatedInstances(instances)...)
+				}
+			}
+		}
+		setInitialDistribution(initialDistributions)
+
+		numEmptyCells := 0
+		for j := 0; j < numCells; j++ {
+			if len(initialDistributions[j]) == 0 {
+				numEmptyCells++
+			}
+		}
+		startAuctions := []models.LRPStartAuction{}
+		for _, instances := range spec.Workload {
+			startAuctions = append(startAuctions, generateLRPStartAuctions(instances, numEmptyCells)...)
+		}
+
+		var schedule auctiondistributor.ArrivalSchedule
+		if spec.Arrival != nil {
+			var err error
+			schedule, err = spec.ArrivalSchedule()
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+
+		report := runStartAuction(spec.Name, startAuctions, startAuctionOrdering(spec.Ordering), schedule, spec.Card[0], spec.Card[1])
+
+		expect := spec.Expect
+		if expect.MaxMissingInstances != nil {
+			Ω(report.NMissingInstances()).Should(BeNumerically("<=", *expect.MaxMissingInstances), "too many missing instances")
+		}
+		if expect.MaxUnplacedAuctions != nil {
+			Ω(report.NUnplacedAuctions).Should(BeNumerically("<=", *expect.MaxUnplacedAuctions), "too many unplaced auctions")
+		}
+		if expect.MaxDistributionScore != nil {
+			Ω(report.NormalizedDistributionScore).Should(BeNumerically("<=", *expect.MaxDistributionScore), "the instances are too unevenly distributed")
+		}
+		if expect.MaxWaitTime != "" {
+			maxWaitTime, err := time.ParseDuration(expect.MaxWaitTime)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(report.WaitTimeStats().Max).Should(BeNumerically("<=", maxWaitTime.Seconds()), "auctions waited too long")
+		}
+	}
+
 	BeforeEach(func() {
 		util.ResetGuids()
 		initialDistributions = map[int][]auctiontypes.SimulatedInstance{}
@@ -110,50 +458,54 @@ var _ = Describe("Two Scenarios", func() {
 		}
 	})
 
-	It("should quickly start 10% of the capacity", func() {
+	for _, spec := range scenarioSpecs {
+		spec := spec
+		It("should run the "+spec.Name+" scenario from "+spec.Path(), func() {
+			runScenarioSpec(spec)
+		})
+	}
+
+	It("should evacuate cells during a rolling deploy", func() {
 		for j := 0; j < numCells; j++ {
-			initialDistributions[j] = generateUniqueSimulatedInstances(util.RandomIntIn(78, 80), 0, 1)
+			initialDistributions[j] = append(generateUniqueSimulatedInstances(40, 0, 1), generateUniqueSimulatedInstances(5, 0, 2)...)
 		}
 		setInitialDistribution(initialDistributions)
 
-		numApps := numCells * 10
-		instances := generateUniqueLRPStartAuctions(numApps, 1)
-		runStartAuction(instances, 0, 0)
+		numEvacuating := numEvacuatingCells
+		if numEvacuating == 0 {
+			numEvacuating = int(math.Ceil(float64(numCells) * 0.05))
+		}
+		runEvacuation("evacuation", numEvacuating, 1, 1)
 	})
 
-	It("should eventually manage a cold start", func() {
-		numOneGigApps := numCells * 40
-		numTwoGigApps := numCells * 20
-		numFourGigApps := numCells * 3
-
-		instances := []models.LRPStartAuction{}
-		colors := []string{"purple", "red", "orange", "teal", "gray", "blue", "pink", "green", "lime", "cyan", "lightseagreen", "brown"}
+	It("should resolve duplicate instances while starting new apps", func() {
+		for j := 0; j < numCells; j++ {
+			initialDistributions[j] = generateUniqueSimulatedInstances(40, 0, 1)
+		}
+		stopAuctions := duplicateInstances(numCells/2, 2)
+		setInitialDistribution(initialDistributions)
 
-		instances = append(instances, generateUniqueLRPStartAuctions(numOneGigApps/2, 1)...)
-		instances = append(instances, generateLRPStartAuctionsWithRandomColor(numOneGigApps/2, 1, colors[:4])...)
-		instances = append(instances, generateUniqueLRPStartAuctions(numTwoGigApps/2, 2)...)
-		instances = append(instances, generateLRPStartAuctionsWithRandomColor(numTwoGigApps/2, 2, colors[4:8])...)
-		instances = append(instances, generateUniqueLRPStartAuctions(numFourGigApps/2, 4)...)
-		instances = append(instances, generateLRPStartAuctionsWithRandomColor(numFourGigApps/2, 4, colors[8:12])...)
+		startAuctions := generateUniqueLRPStartAuctions(numCells*10, 1)
+		runWorkload("duplicates with 10% start", startAuctions, stopAuctions, 0, 1)
+	})
 
-		permutedInstances := make([]models.LRPStartAuction, len(instances))
-		for i, index := range util.R.Perm(len(instances)) {
-			permutedInstances[i] = instances[index]
+	It("should resolve duplicated instances", func() {
+		for j := 0; j < numCells; j++ {
+			initialDistributions[j] = generateUniqueSimulatedInstances(40, 0, 1)
 		}
+		stopAuctions := duplicateInstances(numCells, 2)
+		setInitialDistribution(initialDistributions)
 
-		runStartAuction(instances, 1, 0)
+		runStopAuction("duplicates", stopAuctions)
 	})
 
-	It("should run a rolling deploy", func() {
-		numEmpty := int(math.Ceil(float64(numCells) * 0.05))
-		for j := 0; j < numCells-numEmpty; j++ {
-			initialDistributions[j] = generateUniqueSimulatedInstances(50, 0, 1)
+	It("should resolve instances duplicated across many cells", func() {
+		for j := 0; j < numCells; j++ {
+			initialDistributions[j] = generateUniqueSimulatedInstances(40, 0, 1)
 		}
+		stopAuctions := duplicateInstances(int(math.Ceil(float64(numCells)*0.1)), 5)
 		setInitialDistribution(initialDistributions)
 
-		numApps := numEmpty * 100
-		instances := generateUniqueLRPStartAuctions(numApps, 1)
-
-		runStartAuction(instances, 2, 0)
+		runStopAuction("widespread duplicates", stopAuctions)
 	})
 })
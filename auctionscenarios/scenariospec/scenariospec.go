@@ -0,0 +1,203 @@
+package scenariospec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fraenkel/candiedyaml"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/auctiondistributor"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/ordering"
+)
+
+const DirEnv = "AUCTION_SCENARIOS_DIR"
+
+// Specs without a card of their own are laid out this many to a row.
+const ReportColumns = 3
+
+// A Spec describes a start auction scenario: what the cells are running
+// beforehand, what gets auctioned, how the auctions arrive and what the
+// outcome must look like.  See scenarios/*.json.
+type Spec struct {
+	Name                string      `json:"name" yaml:"name"`
+	Ordering            string      `json:"ordering,omitempty" yaml:"ordering"`
+	Card                []int       `json:"card,omitempty" yaml:"card"`
+	InitialDistribution []CellGroup `json:"initialDistribution,omitempty" yaml:"initialDistribution"`
+	Workload            []Instances `json:"workload" yaml:"workload"`
+	Arrival             *Arrival    `json:"arrival,omitempty" yaml:"arrival"`
+	Expect              Expectation `json:"expect,omitempty" yaml:"expect"`
+
+	path string
+}
+
+// A CellGroup fills the next CellFraction of the cells (rounded down) with
+// Instances.  Cells left over by the groups start empty.
+type CellGroup struct {
+	CellFraction float64     `json:"cellFraction" yaml:"cellFraction"`
+	Instances    []Instances `json:"instances" yaml:"instances"`
+}
+
+// Instances describes a batch of instances, either running on each cell of a
+// group or to be auctioned.  The batch has Count instances, or between Count
+// and MaxCount when MaxCount is set; workloads add PerCell for every cell and
+// PerEmptyCell for every cell that starts empty, rounded down.  Each instance
+// belongs to an app of its own unless Colors are given, in which case it
+// belongs to one of the Colors at random.
+type Instances struct {
+	Count        int      `json:"count,omitempty" yaml:"count"`
+	MaxCount     int      `json:"maxCount,omitempty" yaml:"maxCount"`
+	PerCell      float64  `json:"perCell,omitempty" yaml:"perCell"`
+	PerEmptyCell float64  `json:"perEmptyCell,omitempty" yaml:"perEmptyCell"`
+	MemoryMB     int      `json:"memoryMB" yaml:"memoryMB"`
+	DiskMB       int      `json:"diskMB,omitempty" yaml:"diskMB"`
+	Colors       []string `json:"colors,omitempty" yaml:"colors"`
+}
+
+// An Arrival overrides -arrivalSchedule, -arrivalRate and -arrivalTrace for
+// one scenario.  Traces are relative to the scenario file.
+type Arrival struct {
+	Schedule string  `json:"schedule" yaml:"schedule"`
+	Rate     float64 `json:"rate,omitempty" yaml:"rate"`
+	Trace    string  `json:"trace,omitempty" yaml:"trace"`
+}
+
+type Expectation struct {
+	MaxMissingInstances  *int     `json:"maxMissingInstances,omitempty" yaml:"maxMissingInstances"`
+	MaxUnplacedAuctions  *int     `json:"maxUnplacedAuctions,omitempty" yaml:"maxUnplacedAuctions"`
+	MaxDistributionScore *float64 `json:"maxDistributionScore,omitempty" yaml:"maxDistributionScore"`
+	MaxWaitTime          string   `json:"maxWaitTime,omitempty" yaml:"maxWaitTime"`
+}
+
+// Dir is $AUCTION_SCENARIOS_DIR, or ./scenarios if it isn't set.
+func Dir() string {
+	dir := os.Getenv(DirEnv)
+	if dir == "" {
+		return "./scenarios"
+	}
+	return dir
+}
+
+// Load reads every .json, .yml and .yaml file in dir, in name order.  Specs
+// without a card are given one, ReportColumns to a row, from firstAutoRow on.
+func Load(dir string, firstAutoRow int) ([]Spec, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	specs := []Spec{}
+	autoCards := 0
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		if ext != ".json" && ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		spec := Spec{path: path}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &spec)
+		} else {
+			err = candiedyaml.Unmarshal(data, &spec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario file %s: %s", path, err.Error())
+		}
+		err = spec.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario file %s: %s", path, err.Error())
+		}
+
+		if spec.Name == "" {
+			spec.Name = strings.TrimSuffix(filepath.Base(path), ext)
+		}
+		if len(spec.Card) == 0 {
+			spec.Card = []int{autoCards % ReportColumns, firstAutoRow + autoCards/ReportColumns}
+			autoCards++
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+func (s Spec) Validate() error {
+	if len(s.Card) != 0 && len(s.Card) != 2 {
+		return errors.New("card must be [column, row]")
+	}
+	if len(s.Workload) == 0 {
+		return errors.New("the workload is empty")
+	}
+	_, err := ordering.Apply(s.Ordering, nil)
+	if err != nil {
+		return err
+	}
+	for _, group := range s.InitialDistribution {
+		if group.CellFraction <= 0 || group.CellFraction > 1 {
+			return fmt.Errorf("cellFraction must be in (0, 1], got %f", group.CellFraction)
+		}
+		for _, instances := range group.Instances {
+			err := instances.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, instances := range s.Workload {
+		err := instances.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if s.Arrival != nil {
+		_, err := s.ArrivalSchedule()
+		if err != nil {
+			return err
+		}
+	}
+	if s.Expect.MaxWaitTime != "" {
+		_, err := time.ParseDuration(s.Expect.MaxWaitTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Instances) Validate() error {
+	if s.MemoryMB <= 0 {
+		return errors.New("memoryMB must be positive")
+	}
+	if s.DiskMB < 0 {
+		return errors.New("diskMB must not be negative")
+	}
+	if s.Count < 0 || s.PerCell < 0 || s.PerEmptyCell < 0 {
+		return errors.New("counts must not be negative")
+	}
+	if s.MaxCount != 0 && s.MaxCount < s.Count {
+		return errors.New("maxCount must not be less than count")
+	}
+	return nil
+}
+
+// Path is the file the spec was loaded from.
+func (s Spec) Path() string {
+	return s.path
+}
+
+func (s Spec) ArrivalSchedule() (auctiondistributor.ArrivalSchedule, error) {
+	trace := s.Arrival.Trace
+	if trace != "" && !filepath.IsAbs(trace) {
+		trace = filepath.Join(filepath.Dir(s.path), trace)
+	}
+	return auctiondistributor.NewArrivalSchedule(s.Arrival.Schedule, s.Arrival.Rate, trace)
+}
@@ -0,0 +1,13 @@
+package scenariospec_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestScenarioSpec(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scenario Spec Suite")
+}
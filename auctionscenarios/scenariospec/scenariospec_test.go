@@ -0,0 +1,193 @@
+package scenariospec_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/ordering"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/scenariospec"
+)
+
+var _ = Describe("Spec", func() {
+	validSpec := func() scenariospec.Spec {
+		return scenariospec.Spec{
+			Workload: []scenariospec.Instances{{PerCell: 10, MemoryMB: 1}},
+		}
+	}
+
+	Describe("Validate", func() {
+		validate := func(modify func(*scenariospec.Spec)) error {
+			spec := validSpec()
+			modify(&spec)
+			return spec.Validate()
+		}
+
+		expectError := func(err error, message string) {
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(message))
+		}
+
+		It("accepts a spec with everything set", func() {
+			one := 1
+			err := validate(func(s *scenariospec.Spec) {
+				s.Card = []int{1, 2}
+				s.Ordering = ordering.Grouped
+				s.InitialDistribution = []scenariospec.CellGroup{{CellFraction: 1, Instances: []scenariospec.Instances{{Count: 5, MaxCount: 6, MemoryMB: 1}}}}
+				s.Arrival = &scenariospec.Arrival{Schedule: "burst"}
+				s.Expect = scenariospec.Expectation{MaxMissingInstances: &one, MaxWaitTime: "10s"}
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("needs a workload and a card of [column, row]", func() {
+			expectError(validate(func(s *scenariospec.Spec) { s.Workload = nil }), "the workload is empty")
+			expectError(validate(func(s *scenariospec.Spec) { s.Card = []int{1} }), "card must be [column, row]")
+		})
+
+		It("rejects instances without memory, or with negative disk or counts", func() {
+			expectError(validate(func(s *scenariospec.Spec) { s.Workload[0].MemoryMB = 0 }), "memoryMB must be positive")
+			expectError(validate(func(s *scenariospec.Spec) { s.Workload[0].DiskMB = -1 }), "diskMB must not be negative")
+			expectError(validate(func(s *scenariospec.Spec) { s.Workload[0].PerEmptyCell = -1 }), "counts must not be negative")
+			expectError(validate(func(s *scenariospec.Spec) { s.Workload[0].Count, s.Workload[0].MaxCount = 5, 4 }), "maxCount must not be less than count")
+		})
+
+		It("rejects cell groups outside (0, 1] and their invalid instances", func() {
+			expectError(validate(func(s *scenariospec.Spec) {
+				s.InitialDistribution = []scenariospec.CellGroup{{CellFraction: 0}}
+			}), "cellFraction must be in (0, 1], got 0.000000")
+			expectError(validate(func(s *scenariospec.Spec) {
+				s.InitialDistribution = []scenariospec.CellGroup{{CellFraction: 1.5}}
+			}), "cellFraction must be in (0, 1], got 1.500000")
+			expectError(validate(func(s *scenariospec.Spec) {
+				s.InitialDistribution = []scenariospec.CellGroup{{CellFraction: 1, Instances: []scenariospec.Instances{{Count: 1}}}}
+			}), "memoryMB must be positive")
+		})
+
+		It("rejects unknown orderings, bad arrivals and bad wait times", func() {
+			expectError(validate(func(s *scenariospec.Spec) { s.Ordering = "alphabetical" }), "unknown ordering: alphabetical")
+			expectError(validate(func(s *scenariospec.Spec) { s.Arrival = &scenariospec.Arrival{Schedule: "sometimes"} }), "unknown arrival schedule: sometimes")
+			expectError(validate(func(s *scenariospec.Spec) { s.Arrival = &scenariospec.Arrival{Schedule: "constant"} }), "the constant arrival schedule needs a positive rate")
+			expectError(validate(func(s *scenariospec.Spec) { s.Expect.MaxWaitTime = "soon" }), "time: invalid duration")
+		})
+	})
+
+	Describe("Load", func() {
+		var dir string
+
+		writeFile := func(name string, contents string) {
+			err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0666)
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "scenariospec")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("loads every scenario file in name order, skipping other files", func() {
+			writeFile("2-second.json", `{"name": "second", "card": [2, 0], "workload": [{"perCell": 1, "memoryMB": 1}]}`)
+			writeFile("1-first.json", `{"name": "first", "ordering": "shuffled", "workload": [{"count": 3, "memoryMB": 2}]}`)
+			writeFile("README.md", "not a scenario")
+
+			specs, err := scenariospec.Load(dir, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(specs).Should(HaveLen(2))
+
+			Ω(specs[0].Name).Should(Equal("first"))
+			Ω(specs[0].Ordering).Should(Equal(ordering.Shuffled))
+			Ω(specs[0].Workload).Should(Equal([]scenariospec.Instances{{Count: 3, MemoryMB: 2}}))
+			Ω(specs[0].Path()).Should(Equal(filepath.Join(dir, "1-first.json")))
+
+			Ω(specs[1].Name).Should(Equal("second"))
+			Ω(specs[1].Card).Should(Equal([]int{2, 0}))
+		})
+
+		It("names unnamed scenarios after their file", func() {
+			writeFile("cold-start.json", `{"workload": [{"perCell": 1, "memoryMB": 1}]}`)
+
+			specs, err := scenariospec.Load(dir, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(specs[0].Name).Should(Equal("cold-start"))
+		})
+
+		It("lays out scenarios without a card from the given row on", func() {
+			for _, name := range []string{"a", "b", "c", "d", "e"} {
+				writeFile(name+".json", `{"workload": [{"perCell": 1, "memoryMB": 1}]}`)
+			}
+			writeFile("f.json", `{"card": [0, 0], "workload": [{"perCell": 1, "memoryMB": 1}]}`)
+
+			specs, err := scenariospec.Load(dir, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			cards := [][]int{}
+			for _, spec := range specs {
+				cards = append(cards, spec.Card)
+			}
+			Ω(cards).Should(Equal([][]int{{0, 3}, {1, 3}, {2, 3}, {0, 4}, {1, 4}, {0, 0}}))
+		})
+
+		It("resolves traces relative to the scenario file", func() {
+			writeFile("trace.txt", "0\n0.5\n2\n")
+			writeFile("traced.json", `{"workload": [{"count": 3, "memoryMB": 1}], "arrival": {"schedule": "trace", "trace": "trace.txt"}}`)
+
+			specs, err := scenariospec.Load(dir, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			schedule, err := specs[0].ArrivalSchedule()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(schedule.Arrivals(3)).Should(HaveLen(3))
+		})
+
+		It("fails on an invalid file, naming it", func() {
+			writeFile("broken.json", `{"workload": [{"perCell": 1}]}`)
+
+			_, err := scenariospec.Load(dir, 3)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("broken.json"))
+			Ω(err.Error()).Should(ContainSubstring("memoryMB must be positive"))
+		})
+
+		It("fails on malformed JSON", func() {
+			writeFile("broken.json", `{"workload": [`)
+
+			_, err := scenariospec.Load(dir, 3)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("finds nothing in an empty directory", func() {
+			specs, err := scenariospec.Load(dir, 3)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(specs).Should(BeEmpty())
+		})
+	})
+
+	Describe("Dir", func() {
+		var previous string
+
+		BeforeEach(func() {
+			previous = os.Getenv(scenariospec.DirEnv)
+		})
+
+		AfterEach(func() {
+			os.Setenv(scenariospec.DirEnv, previous)
+		})
+
+		It("defaults to ./scenarios", func() {
+			os.Setenv(scenariospec.DirEnv, "")
+			Ω(scenariospec.Dir()).Should(Equal("./scenarios"))
+		})
+
+		It("comes from the environment", func() {
+			os.Setenv(scenariospec.DirEnv, "/tmp/my-scenarios")
+			Ω(scenariospec.Dir()).Should(Equal("/tmp/my-scenarios"))
+		})
+	})
+})
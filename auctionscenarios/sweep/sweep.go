@@ -0,0 +1,112 @@
+package sweep
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fraenkel/candiedyaml"
+)
+
+// A Sweep lists the runs of auctionscenarios.test to make.  Runs, if given,
+// are run as they are; otherwise every combination of the Dimensions' values
+// is run, the first dimension varying slowest.  Args are passed to every run.
+type Sweep struct {
+	Test       string                   `json:"test" yaml:"test"`
+	Args       []string                 `json:"args,omitempty" yaml:"args"`
+	Dimensions []Dimension              `json:"dimensions,omitempty" yaml:"dimensions"`
+	Runs       []map[string]interface{} `json:"runs,omitempty" yaml:"runs"`
+}
+
+type Dimension struct {
+	Flag   string        `json:"flag" yaml:"flag"`
+	Values []interface{} `json:"values" yaml:"values"`
+}
+
+var Default = Sweep{
+	Test: "./auctionscenarios.test",
+	Dimensions: []Dimension{
+		{Flag: "numCells", Values: []interface{}{25, 50, 100, 150, 200, 250, 300, 350, 400}},
+		{Flag: "maxConcurrent", Values: []interface{}{1, 2, 5}},
+		{Flag: "maxBiddingPoolFraction", Values: []interface{}{0.05, 0.1, 0.2}},
+		{Flag: "algorithm", Values: []interface{}{"compare_to_percentile", "all_rebid"}},
+	},
+}
+
+func Load(path string) (Sweep, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Sweep{}, err
+	}
+
+	s := Sweep{}
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		err = candiedyaml.Unmarshal(data, &s)
+	default:
+		err = json.Unmarshal(data, &s)
+	}
+	if err != nil {
+		return Sweep{}, err
+	}
+
+	if s.Test == "" {
+		s.Test = Default.Test
+	}
+	if len(s.Runs) == 0 && len(s.Dimensions) == 0 {
+		return Sweep{}, fmt.Errorf("%s has neither dimensions nor runs", path)
+	}
+	for _, d := range s.Dimensions {
+		if d.Flag == "" || len(d.Values) == 0 {
+			return Sweep{}, fmt.Errorf("every dimension needs a flag and at least one value")
+		}
+	}
+	return s, nil
+}
+
+// Combinations returns the flags of every run.
+func (s Sweep) Combinations() [][]string {
+	if len(s.Runs) > 0 {
+		combinations := [][]string{}
+		for _, run := range s.Runs {
+			flags := []string{}
+			for name := range run {
+				flags = append(flags, name)
+			}
+			sort.Strings(flags)
+
+			args := []string{}
+			for _, name := range flags {
+				args = append(args, flagArg(name, run[name]))
+			}
+			combinations = append(combinations, args)
+		}
+		return combinations
+	}
+
+	combinations := [][]string{{}}
+	for _, d := range s.Dimensions {
+		next := [][]string{}
+		for _, combination := range combinations {
+			for _, value := range d.Values {
+				args := append(append([]string{}, combination...), flagArg(d.Flag, value))
+				next = append(next, args)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// flagArg formats numbers without exponents, since JSON decodes them all as
+// float64 and integer flags can't parse 1e+06.
+func flagArg(name string, value interface{}) string {
+	if f, ok := value.(float64); ok {
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("--%s=%v", strings.TrimLeft(name, "-"), value)
+}
@@ -0,0 +1,13 @@
+package sweep_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sweep Suite")
+}
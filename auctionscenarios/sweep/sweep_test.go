@@ -0,0 +1,104 @@
+package sweep_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/sweep"
+)
+
+var _ = Describe("Sweep", func() {
+	Describe("Combinations", func() {
+		It("varies the first dimension slowest", func() {
+			s := sweep.Sweep{Dimensions: []sweep.Dimension{
+				{Flag: "numCells", Values: []interface{}{25, 50}},
+				{Flag: "algorithm", Values: []interface{}{"all_rebid", "compare_to_percentile"}},
+			}}
+			Ω(s.Combinations()).Should(Equal([][]string{
+				{"--numCells=25", "--algorithm=all_rebid"},
+				{"--numCells=25", "--algorithm=compare_to_percentile"},
+				{"--numCells=50", "--algorithm=all_rebid"},
+				{"--numCells=50", "--algorithm=compare_to_percentile"},
+			}))
+		})
+
+		It("formats decoded numbers without exponents and trims leading dashes from flags", func() {
+			s := sweep.Sweep{Dimensions: []sweep.Dimension{
+				{Flag: "--numAuctions", Values: []interface{}{float64(1000000), 0.05}},
+				{Flag: "-maxConcurrent", Values: []interface{}{1}},
+			}}
+			Ω(s.Combinations()).Should(Equal([][]string{
+				{"--numAuctions=1000000", "--maxConcurrent=1"},
+				{"--numAuctions=0.05", "--maxConcurrent=1"},
+			}))
+		})
+
+		It("runs explicit runs as they are, sorting their flags", func() {
+			s := sweep.Sweep{
+				Dimensions: []sweep.Dimension{{Flag: "numCells", Values: []interface{}{25}}},
+				Runs: []map[string]interface{}{
+					{"numCells": float64(100), "algorithm": "all_rebid"},
+					{"maxConcurrent": float64(5)},
+				},
+			}
+			Ω(s.Combinations()).Should(Equal([][]string{
+				{"--algorithm=all_rebid", "--numCells=100"},
+				{"--maxConcurrent=5"},
+			}))
+		})
+	})
+
+	Describe("Load", func() {
+		var dir string
+
+		writeSweep := func(contents string) string {
+			path := filepath.Join(dir, "sweep.json")
+			err := ioutil.WriteFile(path, []byte(contents), 0666)
+			Ω(err).ShouldNot(HaveOccurred())
+			return path
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "sweep")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("loads a JSON sweep, defaulting the test binary", func() {
+			s, err := sweep.Load(writeSweep(`{"args": ["--numAuctioneers=10"], "dimensions": [{"flag": "numCells", "values": [100, 200]}]}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(s.Test).Should(Equal(sweep.Default.Test))
+			Ω(s.Args).Should(Equal([]string{"--numAuctioneers=10"}))
+			Ω(s.Combinations()).Should(Equal([][]string{{"--numCells=100"}, {"--numCells=200"}}))
+		})
+
+		It("keeps the given test binary", func() {
+			s, err := sweep.Load(writeSweep(`{"test": "./other.test", "runs": [{"numCells": 10}]}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(s.Test).Should(Equal("./other.test"))
+		})
+
+		It("rejects missing and malformed files", func() {
+			_, err := sweep.Load(filepath.Join(dir, "missing.json"))
+			Ω(err).Should(HaveOccurred())
+			_, err = sweep.Load(writeSweep(`{"dimensions": [`))
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("rejects sweeps with nothing to run", func() {
+			_, err := sweep.Load(writeSweep(`{"test": "./auctionscenarios.test"}`))
+			Ω(err).Should(HaveOccurred())
+			_, err = sweep.Load(writeSweep(`{"dimensions": [{"values": [1]}]}`))
+			Ω(err).Should(HaveOccurred())
+			_, err = sweep.Load(writeSweep(`{"dimensions": [{"flag": "numCells", "values": []}]}`))
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -24,19 +24,67 @@ const WAIT_TIME = "wait_time"
 const BIDDING_TIME = "bidding_time"
 const SCORE = "score"
 const NUM_MISSING = "num_missing"
+const NUM_FAILED_AUCTIONEERS = "num_failed_auctioneers"
+const NUM_LOST_AUCTIONS = "num_lost_auctions"
+const PARTITION_STRATEGY = "partition_strategy"
+const ARRIVAL_SCHEDULE = "arrival_schedule"
+const KIND = "kind"
+const NUM_DUPLICATES_STOPPED = "num_duplicates_stopped"
+const NUM_DUPLICATES_REMAINING = "num_duplicates_remaining"
+const NUM_KEEPING_REPS = "num_keeping_reps"
+const NUM_EVACUATED_CELLS = "num_evacuated_cells"
+const NUM_UNPLACED = "num_unplaced"
+const FAULT_MODE = "fault_mode"
+const NUM_FAULTY_CELLS = "num_faulty_cells"
+const MEAN_ROUNDS = "mean_rounds"
+const MAX_ROUNDS = "max_rounds"
+const REP_LATENCY = "rep_latency"
+const CELL_MIX = "cell_mix"
+const NORMALIZED_SCORE = "normalized_score"
+const NUM_ZONES = "num_zones"
+const ZONE_BALANCE = "zone_balance"
+const SEED = "seed"
+const ORDERING = "ordering"
+const NUM_STOP_AUCTIONS = "num_stop_auctions"
+const STOP_COMMUNICATIONS = "stop_communications"
+const STOP_WAIT_TIME = "stop_wait_time"
 
 type Summary struct {
-	Cells               int
-	Concurrency         int
-	BiddingPoolFraction float64
-	Algorithm           string
-	Scenario            string
-	NumAuctions         int
-	Communication       int
-	WaitTime            float64
-	BiddingTime         float64
-	Score               float64
-	NumMissing          int
+	Cells                  int
+	Concurrency            int
+	BiddingPoolFraction    float64
+	Algorithm              string
+	Scenario               string
+	NumAuctions            int
+	Communication          int
+	WaitTime               float64
+	BiddingTime            float64
+	Score                  float64
+	NumMissing             int
+	NumFailedAuctioneers   int
+	NumLostAuctions        int
+	PartitionStrategy      string
+	ArrivalSchedule        string
+	Kind                   string
+	NumDuplicatesStopped   int
+	NumDuplicatesRemaining int
+	NumKeepingReps         int
+	NumEvacuatedCells      int
+	NumUnplaced            int
+	FaultMode              string
+	NumFaultyCells         int
+	MeanRounds             float64
+	MaxRounds              int
+	RepLatency             string
+	CellMix                string
+	NormalizedScore        float64
+	NumZones               int
+	ZoneBalance            float64
+	Seed                   string
+	Ordering               string
+	NumStopAuctions        int
+	StopCommunication      int
+	StopWaitTime           float64
 }
 
 func (s Summary) Get(key string) interface{} {
@@ -63,6 +111,54 @@ func (s Summary) Get(key string) interface{} {
 		return s.Score
 	case NUM_MISSING:
 		return s.NumMissing
+	case NUM_FAILED_AUCTIONEERS:
+		return s.NumFailedAuctioneers
+	case NUM_LOST_AUCTIONS:
+		return s.NumLostAuctions
+	case PARTITION_STRATEGY:
+		return s.PartitionStrategy
+	case ARRIVAL_SCHEDULE:
+		return s.ArrivalSchedule
+	case KIND:
+		return s.Kind
+	case NUM_DUPLICATES_STOPPED:
+		return s.NumDuplicatesStopped
+	case NUM_DUPLICATES_REMAINING:
+		return s.NumDuplicatesRemaining
+	case NUM_KEEPING_REPS:
+		return s.NumKeepingReps
+	case NUM_EVACUATED_CELLS:
+		return s.NumEvacuatedCells
+	case NUM_UNPLACED:
+		return s.NumUnplaced
+	case FAULT_MODE:
+		return s.FaultMode
+	case NUM_FAULTY_CELLS:
+		return s.NumFaultyCells
+	case MEAN_ROUNDS:
+		return s.MeanRounds
+	case MAX_ROUNDS:
+		return s.MaxRounds
+	case REP_LATENCY:
+		return s.RepLatency
+	case CELL_MIX:
+		return s.CellMix
+	case NORMALIZED_SCORE:
+		return s.NormalizedScore
+	case NUM_ZONES:
+		return s.NumZones
+	case ZONE_BALANCE:
+		return s.ZoneBalance
+	case SEED:
+		return s.Seed
+	case ORDERING:
+		return s.Ordering
+	case NUM_STOP_AUCTIONS:
+		return s.NumStopAuctions
+	case STOP_COMMUNICATIONS:
+		return s.StopCommunication
+	case STOP_WAIT_TIME:
+		return s.StopWaitTime
 	default:
 		log.Fatalf("Unkown key: %s", key)
 	}
@@ -92,6 +188,14 @@ func ParseInt(s string) int {
 	return int(i)
 }
 
+// ParseOptionalInt parses columns that older summaries don't have.
+func ParseOptionalInt(s string) int {
+	if s == "" {
+		return 0
+	}
+	return ParseInt(s)
+}
+
 func ParseFloat(s string) float64 {
 	f, err := strconv.ParseFloat(s, 64)
 	if err != nil {
@@ -100,29 +204,84 @@ func ParseFloat(s string) float64 {
 	return f
 }
 
+func ParseOptionalFloat(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	return ParseFloat(s)
+}
+
 func LoadSummaries(path string) Summaries {
 	f, err := os.Open(path)
 	if err != nil {
 		log.Fatalf("Failed to open summary file: %s", err.Error())
 	}
-	records, err := csv.NewReader(f).ReadAll()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
 	if err != nil {
 		log.Fatalf("Failed to read summary file: %s", err.Error())
 	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
 	summaries := Summaries{}
 	for _, record := range records[1:] {
 		summary := Summary{
-			Cells:               ParseInt(record[0]),
-			Concurrency:         ParseInt(record[2]),
-			BiddingPoolFraction: ParseFloat(record[3]),
-			Algorithm:           record[4],
-			Scenario:            record[5],
-			NumAuctions:         ParseInt(record[6]),
-			Communication:       ParseInt(record[7]),
-			WaitTime:            ParseFloat(record[8]),
-			BiddingTime:         ParseFloat(record[9]),
-			Score:               ParseFloat(record[10]),
-			NumMissing:          ParseInt(record[11]),
+			Cells:                  ParseInt(field(record, "numCells")),
+			Concurrency:            ParseInt(field(record, "concurrentAuctionsPerAuctioneer")),
+			BiddingPoolFraction:    ParseFloat(field(record, "maxBiddingPoolFraction")),
+			Algorithm:              field(record, "algorithm"),
+			Scenario:               field(record, "scenario"),
+			NumAuctions:            ParseInt(field(record, "# auctions")),
+			Communication:          ParseInt(field(record, "communication")),
+			WaitTime:               ParseFloat(field(record, "waitTime")),
+			BiddingTime:            ParseFloat(field(record, "biddingTime")),
+			Score:                  ParseFloat(field(record, "distributionScore")),
+			NumMissing:             ParseInt(field(record, "nMissing")),
+			NumFailedAuctioneers:   ParseOptionalInt(field(record, "nFailedAuctioneers")),
+			NumLostAuctions:        ParseOptionalInt(field(record, "nLostAuctions")),
+			PartitionStrategy:      field(record, "partitionStrategy"),
+			ArrivalSchedule:        field(record, "arrivalSchedule"),
+			Kind:                   field(record, "kind"),
+			NumDuplicatesStopped:   ParseOptionalInt(field(record, "nDuplicatesStopped")),
+			NumDuplicatesRemaining: ParseOptionalInt(field(record, "nDuplicatesRemaining")),
+			NumKeepingReps:         ParseOptionalInt(field(record, "nKeepingReps")),
+			NumEvacuatedCells:      ParseOptionalInt(field(record, "nEvacuatedCells")),
+			NumUnplaced:            ParseOptionalInt(field(record, "nUnplaced")),
+			FaultMode:              field(record, "faultMode"),
+			NumFaultyCells:         ParseOptionalInt(field(record, "nFaultyCells")),
+			MeanRounds:             ParseOptionalFloat(field(record, "meanRounds")),
+			MaxRounds:              ParseOptionalInt(field(record, "maxRounds")),
+			RepLatency:             field(record, "repLatency"),
+			CellMix:                field(record, "cellMix"),
+			NormalizedScore:        ParseOptionalFloat(field(record, "normalizedDistributionScore")),
+			NumZones:               ParseOptionalInt(field(record, "nZones")),
+			ZoneBalance:            ParseOptionalFloat(field(record, "zoneBalance")),
+			Seed:                   field(record, "seed"),
+			Ordering:               field(record, "ordering"),
+			NumStopAuctions:        ParseOptionalInt(field(record, "nStopAuctions")),
+			StopCommunication:      ParseOptionalInt(field(record, "stopCommunication")),
+			StopWaitTime:           ParseOptionalFloat(field(record, "stopWaitTime")),
+		}
+		if summary.Kind == "" {
+			summary.Kind = "start"
+		}
+		if summary.FaultMode == "" {
+			summary.FaultMode = "none"
+		}
+		if field(record, "normalizedDistributionScore") == "" {
+			summary.NormalizedScore = summary.Score
 		}
 		summaries = append(summaries, summary)
 	}
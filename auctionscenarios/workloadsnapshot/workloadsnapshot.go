@@ -0,0 +1,89 @@
+package workloadsnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+)
+
+// A Snapshot holds the generated inputs of a scenario: the instances each rep
+// starts with, keyed by rep guid, and the auctions to hold, start auctions in
+// the order they were submitted.  Evacuation scenarios also record which reps
+// were evacuated.
+type Snapshot struct {
+	Scenario            string
+	Seed                int64
+	Ordering            string `json:",omitempty"`
+	InitialDistribution map[string][]auctiontypes.SimulatedInstance
+	StartAuctions       []models.LRPStartAuction `json:",omitempty"`
+	StopAuctions        []models.LRPStopAuction  `json:",omitempty"`
+	EvacuatedReps       []string                 `json:",omitempty"`
+}
+
+// Path is where the scenario's snapshot lives in dir: its name, lower cased,
+// with anything but letters and digits replaced by dashes.
+func Path(dir string, scenario string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(scenario))
+	return filepath.Join(dir, name+".json")
+}
+
+func Export(dir string, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(Path(dir, snapshot.Scenario), data, 0666)
+}
+
+func Load(dir string, scenario string) (Snapshot, error) {
+	data, err := ioutil.ReadFile(Path(dir, scenario))
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var snapshot Snapshot
+	err = json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
+
+// Seed returns the seed the snapshots in dir were generated with.  Snapshots
+// exported by one run share its seed, so a replay can seed its own random
+// choices the same way before generating anything.
+func Seed(dir string) (int64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	if len(paths) == 0 {
+		return 0, fmt.Errorf("no workloads to replay in %s", dir)
+	}
+
+	var seed int64
+	for i, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+
+		var snapshot Snapshot
+		err = json.Unmarshal(data, &snapshot)
+		if err != nil {
+			return 0, fmt.Errorf("invalid workload %s: %s", path, err.Error())
+		}
+		if i > 0 && snapshot.Seed != seed {
+			return 0, fmt.Errorf("the workloads in %s were generated with different seeds", dir)
+		}
+		seed = snapshot.Seed
+	}
+	return seed, nil
+}
@@ -0,0 +1,96 @@
+package workloadsnapshot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/auction/auctiontypes"
+	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf-experimental/diego-cluster-simulations/auctionscenarios/workloadsnapshot"
+)
+
+var _ = Describe("Snapshots", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "workloadsnapshot")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("names the snapshot after the scenario, with anything but letters and digits replaced by dashes", func() {
+		Ω(workloadsnapshot.Path(dir, "cold start")).Should(Equal(filepath.Join(dir, "cold-start.json")))
+		Ω(workloadsnapshot.Path(dir, "Cold Start (shuffled)")).Should(Equal(filepath.Join(dir, "cold-start--shuffled-.json")))
+		Ω(workloadsnapshot.Path(dir, "10% start")).Should(Equal(filepath.Join(dir, "10--start.json")))
+	})
+
+	It("loads what was exported, start auctions in order", func() {
+		snapshot := workloadsnapshot.Snapshot{
+			Scenario: "cold start",
+			Seed:     42,
+			Ordering: "shuffled",
+			InitialDistribution: map[string][]auctiontypes.SimulatedInstance{
+				"rep-lite-1": {{ProcessGuid: "a", InstanceGuid: "a-0", Index: 0, MemoryMB: 1, DiskMB: 1}},
+				"rep-lite-2": {},
+			},
+			StartAuctions: []models.LRPStartAuction{
+				{DesiredLRP: models.DesiredLRP{ProcessGuid: "c", MemoryMB: 4}, InstanceGuid: "c-0"},
+				{DesiredLRP: models.DesiredLRP{ProcessGuid: "b", MemoryMB: 1}, InstanceGuid: "b-0"},
+				{DesiredLRP: models.DesiredLRP{ProcessGuid: "c", MemoryMB: 4}, InstanceGuid: "c-1", Index: 1},
+			},
+			StopAuctions:  []models.LRPStopAuction{{ProcessGuid: "a", Index: 0}},
+			EvacuatedReps: []string{"rep-lite-2"},
+		}
+
+		err := workloadsnapshot.Export(dir, snapshot)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		loaded, err := workloadsnapshot.Load(dir, "cold start")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(loaded).Should(Equal(snapshot))
+	})
+
+	It("fails to load a scenario that wasn't exported", func() {
+		_, err := workloadsnapshot.Load(dir, "cold start")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("fails to load a malformed snapshot", func() {
+		err := ioutil.WriteFile(workloadsnapshot.Path(dir, "cold start"), []byte("{"), 0666)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = workloadsnapshot.Load(dir, "cold start")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("finds the seed the snapshots share", func() {
+		err := workloadsnapshot.Export(dir, workloadsnapshot.Snapshot{Scenario: "cold start", Seed: 42})
+		Ω(err).ShouldNot(HaveOccurred())
+		err = workloadsnapshot.Export(dir, workloadsnapshot.Snapshot{Scenario: "duplicates", Seed: 42})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		seed, err := workloadsnapshot.Seed(dir)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(seed).Should(Equal(int64(42)))
+	})
+
+	It("fails to find a seed without snapshots or with mixed seeds", func() {
+		_, err := workloadsnapshot.Seed(dir)
+		Ω(err).Should(HaveOccurred())
+
+		err = workloadsnapshot.Export(dir, workloadsnapshot.Snapshot{Scenario: "cold start", Seed: 42})
+		Ω(err).ShouldNot(HaveOccurred())
+		err = workloadsnapshot.Export(dir, workloadsnapshot.Snapshot{Scenario: "duplicates", Seed: 43})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = workloadsnapshot.Seed(dir)
+		Ω(err).Should(HaveOccurred())
+	})
+})